@@ -0,0 +1,205 @@
+package logr
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sync"
+    "time"
+)
+
+// maxLevelRequestSize bounds the body of a level change request
+const maxLevelRequestSize = 64 * 1024
+
+// LevelHandler serves the root and component levels of a logger as JSON, so
+// that they can be changed at runtime, e.g. during an incident:
+//
+//    GET  returns the levels as a LevelState
+//    PUT  applies a LevelRequest and returns the new LevelState
+//
+// Mount it on an existing mux, e.g. mux.Handle("/debug/log/levels", handler).
+// The handler has no authentication of its own.
+type LevelHandler struct {
+    logger  *Logger
+    mu      sync.Mutex
+    reverts map[string]*levelRevert // Pending reverts by component pattern, "" for the root level
+}
+
+// LevelRequest changes the root level, or the level of a component if
+// Component is set. A nil Level removes the component's level, so it falls
+// back to other patterns or the root level. If RevertAfter is set, such as
+// "10m", the previous level is restored once it has elapsed.
+type LevelRequest struct {
+    Component   string    `json:"component,omitempty"`
+    Level       *LogLevel `json:"level"`
+    RevertAfter string    `json:"revert_after,omitempty"`
+}
+
+// LevelState is the level configuration served by LevelHandler
+type LevelState struct {
+    Level      LogLevel                  `json:"level"`               // Root level
+    RevertAt   *time.Time                `json:"revert_at,omitempty"` // When the root level reverts, if pending
+    Components map[string]ComponentLevel `json:"components"`          // Levels set by name or pattern
+    Loggers    map[string]LogLevel       `json:"loggers"`             // Effective levels of the named loggers
+}
+
+// ComponentLevel is the level set for a component name or pattern. A level
+// removed with a pending revert is listed without a level until it reverts.
+type ComponentLevel struct {
+    Level    *LogLevel  `json:"level"`
+    RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// levelRevert restores a level when its timer fires
+type levelRevert struct {
+    timer    *time.Timer
+    at       time.Time
+    previous *LogLevel // Level before the first change, nil if the component had none
+}
+
+// NewLevelHandler creates a handler serving the levels of logger
+func NewLevelHandler(logger *Logger) *LevelHandler {
+    return &LevelHandler{logger: logger, reverts: make(map[string]*levelRevert)}
+}
+
+// ServeHTTP implements http.Handler
+func (h *LevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+    case http.MethodPut:
+        var req LevelRequest
+        decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLevelRequestSize))
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&req); err != nil {
+            http.Error(w, fmt.Sprintf("invalid level request: %v", err), http.StatusBadRequest)
+            return
+        }
+        if err := h.apply(req); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    default:
+        w.Header().Set("Allow", "GET, PUT")
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(h.State())
+}
+
+// apply validates and applies a level change
+func (h *LevelHandler) apply(req LevelRequest) error {
+    var revertAfter time.Duration
+    if req.RevertAfter != "" {
+        var err error
+        if revertAfter, err = time.ParseDuration(req.RevertAfter); err != nil || revertAfter <= 0 {
+            return fmt.Errorf("invalid revert_after %q", req.RevertAfter)
+        }
+    }
+    if req.Component == "" && req.Level == nil {
+        return fmt.Errorf("the root level cannot be removed")
+    }
+    if req.Component != "" {
+        if err := validatePattern(req.Component); err != nil {
+            return err
+        }
+    }
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    // A pending revert keeps the level from before the first change
+    previous := h.currentLevel(req.Component)
+    if pending, ok := h.reverts[req.Component]; ok {
+        pending.timer.Stop()
+        previous = pending.previous
+        delete(h.reverts, req.Component)
+    }
+
+    h.setLevel(req.Component, req.Level)
+    if revertAfter > 0 {
+        revert := &levelRevert{at: time.Now().Add(revertAfter), previous: previous}
+        revert.timer = time.AfterFunc(revertAfter, func() {
+            h.revert(req.Component, revert)
+        })
+        h.reverts[req.Component] = revert
+    }
+    return nil
+}
+
+// revert restores the level a revert was scheduled for, unless a later
+// change replaced it
+func (h *LevelHandler) revert(component string, revert *levelRevert) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.reverts[component] != revert {
+        return
+    }
+    delete(h.reverts, component)
+    h.setLevel(component, revert.previous)
+}
+
+// currentLevel returns the level of a component pattern, or the root level
+// if component is empty. h.mu must be held.
+func (h *LevelHandler) currentLevel(component string) *LogLevel {
+    if component == "" {
+        level := h.logger.loadRootLevel()
+        return &level
+    }
+    level, ok := h.logger.ComponentLevels()[component]
+    if !ok {
+        return nil
+    }
+    return &level
+}
+
+// setLevel sets or removes the level of a component pattern, or sets the
+// root level if component is empty. h.mu must be held.
+func (h *LevelHandler) setLevel(component string, level *LogLevel) {
+    switch {
+    case component == "":
+        h.logger.setRootLevel(*level)
+    case level == nil:
+        h.logger.ResetComponentLevel(component)
+    default:
+        h.logger.SetComponentLevel(component, *level)
+    }
+}
+
+// Stop cancels the pending reverts, leaving the current levels in place
+func (h *LevelHandler) Stop() {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    for component, revert := range h.reverts {
+        revert.timer.Stop()
+        delete(h.reverts, component)
+    }
+}
+
+// State returns the current levels and pending reverts
+func (h *LevelHandler) State() LevelState {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+
+    state := LevelState{
+        Level:      h.logger.loadRootLevel(),
+        Components: make(map[string]ComponentLevel),
+        Loggers:    h.logger.effectiveLevels(),
+    }
+    for pattern, level := range h.logger.ComponentLevels() {
+        level := level
+        state.Components[pattern] = ComponentLevel{Level: &level}
+    }
+    for component, revert := range h.reverts {
+        at := revert.at
+        if component == "" {
+            state.RevertAt = &at
+            continue
+        }
+        c := state.Components[component]
+        c.RevertAt = &at
+        state.Components[component] = c
+    }
+    return state
+}
@@ -0,0 +1,101 @@
+package logr
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestLevelHandler(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_admin"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := NewLogger(&Config{LogDir: tempDir, FileName: "admin", MaxSize: 1024 * 1024, Level: INFO})
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+    wal := logger.Named("storage.wal")
+
+    handler := NewLevelHandler(logger)
+    defer handler.Stop()
+    mux := http.NewServeMux()
+    mux.Handle("/debug/log/levels", handler)
+    server := httptest.NewServer(mux)
+    defer server.Close()
+
+    do := func(method, body string) (int, LevelState) {
+        req, _ := http.NewRequest(method, server.URL+"/debug/log/levels", strings.NewReader(body))
+        resp, err := http.DefaultClient.Do(req)
+        if err != nil {
+            t.Fatalf("%s failed: %v", method, err)
+        }
+        defer resp.Body.Close()
+        var state LevelState
+        if resp.StatusCode == http.StatusOK {
+            if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+                t.Fatalf("invalid response: %v", err)
+            }
+        }
+        return resp.StatusCode, state
+    }
+
+    code, state := do(http.MethodGet, "")
+    if code != http.StatusOK || state.Level != INFO || state.Loggers["storage.wal"] != INFO || len(state.Components) != 0 {
+        t.Fatalf("unexpected state %d %+v", code, state)
+    }
+
+    // A component level with auto-revert, changed again before it reverts
+    code, state = do(http.MethodPut, `{"component": "storage.*", "level": "DEBUG", "revert_after": "50ms"}`)
+    if code != http.StatusOK || wal.GetLevel() != DEBUG || state.Components["storage.*"].RevertAt == nil {
+        t.Fatalf("unexpected state %d %+v", code, state)
+    }
+    do(http.MethodPut, `{"component": "storage.*", "level": "WARN", "revert_after": "100ms"}`)
+    if wal.GetLevel() != WARN {
+        t.Errorf("expected WARN, got %v", wal.GetLevel())
+    }
+
+    // The root level without revert
+    code, state = do(http.MethodPut, `{"level": "error"}`)
+    if code != http.StatusOK || state.Level != ERROR || logger.GetLevel() != ERROR || state.RevertAt != nil {
+        t.Fatalf("unexpected state %d %+v", code, state)
+    }
+
+    // Reverting removes the component level the first change added
+    deadline := time.Now().Add(5 * time.Second)
+    for wal.GetLevel() != ERROR && time.Now().Before(deadline) {
+        time.Sleep(10 * time.Millisecond)
+    }
+    if _, state = do(http.MethodGet, ""); len(state.Components) != 0 || state.Loggers["storage.wal"] != ERROR {
+        t.Errorf("expected the component level to revert, got %+v", state)
+    }
+
+    // The root level reverts to its previous value
+    do(http.MethodPut, `{"level": "DEBUG", "revert_after": "20ms"}`)
+    for logger.GetLevel() != ERROR && time.Now().Before(deadline) {
+        time.Sleep(10 * time.Millisecond)
+    }
+    if logger.GetLevel() != ERROR {
+        t.Errorf("expected the root level to revert to ERROR, got %v", logger.GetLevel())
+    }
+
+    for _, body := range []string{
+        `{"level": "LOUD"}`,
+        `{}`,
+        `{"component": "a*", "level": "INFO"}`,
+        `{"level": "INFO", "revert_after": "soon"}`,
+        `{"level": "INFO", "extra": 1}`,
+    } {
+        if code, _ := do(http.MethodPut, body); code != http.StatusBadRequest {
+            t.Errorf("expected 400 for %s, got %d", body, code)
+        }
+    }
+    if code, _ := do(http.MethodPost, `{"level": "INFO"}`); code != http.StatusMethodNotAllowed {
+        t.Errorf("expected 405, got %d", code)
+    }
+}
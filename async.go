@@ -0,0 +1,214 @@
+package logr
+
+import (
+    "sync/atomic"
+)
+
+// OverflowPolicy controls what happens when the async queue is full
+type OverflowPolicy int
+
+const (
+    OverflowBlock          OverflowPolicy = iota // Block the caller until there is room
+    OverflowDropNewest                           // Drop the record being logged
+    OverflowDropOldest                           // Drop the oldest queued record to make room
+    OverflowDropBelowLevel                       // Drop records below Config.OverflowLevel, block for the rest
+)
+
+const (
+    defaultQueueSize = 4096 // Default async queue capacity
+    maxAsyncBatch    = 256  // Maximum number of entries written per batch
+)
+
+// batchSink is implemented by sinks that can write several entries at once
+type batchSink interface {
+    WriteBatch(entries []*Entry) error
+}
+
+// startAsync creates the async queue and starts the writer goroutine
+func (l *Logger) startAsync() {
+    size := l.config.QueueSize
+    if size <= 0 {
+        size = defaultQueueSize
+    }
+    l.queue = make(chan *Entry, size)
+    l.flushChan = make(chan chan error)
+    l.asyncDone = make(chan struct{})
+    go l.asyncRoutine()
+}
+
+// enqueue adds an entry to the async queue, applying the overflow policy when it is full
+func (l *Logger) enqueue(entry *Entry) {
+    // Nothing writes the queue once the writer has stopped. Checked first, as
+    // a select would pick the send at random while there is room.
+    select {
+    case <-l.asyncDone:
+        atomic.AddUint64(&l.dropped, 1)
+        return
+    default:
+    }
+
+    // Fast path: there is room in the queue
+    select {
+    case l.queue <- entry:
+        l.checkQueued()
+        return
+    default:
+    }
+
+    switch l.config.OverflowPolicy {
+    case OverflowDropNewest:
+        atomic.AddUint64(&l.dropped, 1)
+    case OverflowDropOldest:
+        for {
+            select {
+            case l.queue <- entry:
+                l.checkQueued()
+                return
+            case <-l.asyncDone:
+                atomic.AddUint64(&l.dropped, 1)
+                return
+            default:
+            }
+            select {
+            case <-l.queue:
+                atomic.AddUint64(&l.dropped, 1)
+            default:
+            }
+        }
+    case OverflowDropBelowLevel:
+        if entry.Level < l.config.OverflowLevel {
+            atomic.AddUint64(&l.dropped, 1)
+            return
+        }
+        l.enqueueBlocking(entry)
+    default:
+        l.enqueueBlocking(entry)
+    }
+}
+
+// enqueueBlocking waits for room in the queue unless the writer has stopped
+func (l *Logger) enqueueBlocking(entry *Entry) {
+    select {
+    case l.queue <- entry:
+        l.checkQueued()
+    case <-l.asyncDone:
+        atomic.AddUint64(&l.dropped, 1)
+    }
+}
+
+// checkQueued is called after an entry was queued. If the writer stopped in
+// the meantime, nothing will write the queue any more, so an entry is taken
+// back and counted as dropped.
+func (l *Logger) checkQueued() {
+    select {
+    case <-l.asyncDone:
+        l.dropQueued()
+    default:
+    }
+}
+
+// dropQueued empties the queue of a stopped writer, counting the entries as
+// dropped
+func (l *Logger) dropQueued() {
+    for {
+        select {
+        case <-l.queue:
+            atomic.AddUint64(&l.dropped, 1)
+        default:
+            return
+        }
+    }
+}
+
+// asyncRoutine is the goroutine draining the async queue into the sinks
+func (l *Logger) asyncRoutine() {
+    batch := make([]*Entry, 0, maxAsyncBatch)
+    for {
+        select {
+        case entry := <-l.queue:
+            batch = l.collectBatch(append(batch[:0], entry))
+            l.mu.Lock()
+            l.writeBatch(batch)
+            l.mu.Unlock()
+        case done := <-l.flushChan:
+            l.mu.Lock()
+            l.drainQueue(batch)
+            err := l.syncSinks()
+            l.mu.Unlock()
+            done <- err
+        case <-l.stopChan:
+            l.mu.Lock()
+            l.drainQueue(batch)
+            l.mu.Unlock()
+
+            // Entries queued after the drain are counted as dropped
+            close(l.asyncDone)
+            l.dropQueued()
+            return
+        }
+    }
+}
+
+// collectBatch appends queued entries to batch without blocking
+func (l *Logger) collectBatch(batch []*Entry) []*Entry {
+    for len(batch) < maxAsyncBatch {
+        select {
+        case entry := <-l.queue:
+            batch = append(batch, entry)
+        default:
+            return batch
+        }
+    }
+    return batch
+}
+
+// drainQueue writes every queued entry to the sinks
+func (l *Logger) drainQueue(batch []*Entry) {
+    for {
+        batch = l.collectBatch(batch[:0])
+        if len(batch) == 0 {
+            return
+        }
+        l.writeBatch(batch)
+    }
+}
+
+// writeBatch delivers entries to every sink, in one call for sinks that support batches
+func (l *Logger) writeBatch(entries []*Entry) {
+    for _, s := range l.sinks {
+        if bs, ok := s.sink.(batchSink); ok {
+            enabled := entries[:0:0]
+            for _, e := range entries {
+                if s.sink.Enabled(e.Level) {
+                    enabled = append(enabled, e)
+                }
+            }
+            if len(enabled) == 0 {
+                continue
+            }
+            if err := bs.WriteBatch(enabled); err != nil {
+                reportSinkError(s.name, err)
+            }
+            continue
+        }
+        for _, e := range entries {
+            if !s.sink.Enabled(e.Level) {
+                continue
+            }
+            if err := s.sink.Write(e); err != nil {
+                reportSinkError(s.name, err)
+            }
+        }
+    }
+}
+
+// flushAsync waits until every queued entry is written and the sinks are synced
+func (l *Logger) flushAsync() error {
+    done := make(chan error, 1)
+    select {
+    case l.flushChan <- done:
+    case <-l.asyncDone:
+        return nil
+    }
+    return <-done
+}
@@ -0,0 +1,169 @@
+package logr
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// blockingSink blocks every write until released
+type blockingSink struct {
+    release chan struct{}
+    written []string
+}
+
+func (s *blockingSink) Enabled(level LogLevel) bool { return true }
+
+func (s *blockingSink) Write(e *Entry) error {
+    <-s.release
+    s.written = append(s.written, e.Message)
+    return nil
+}
+
+func (s *blockingSink) Sync() error  { return nil }
+func (s *blockingSink) Close() error { return nil }
+
+func TestAsyncWriteDrainsOnSyncAndClose(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_async"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:       tempDir,
+        FileName:     "async_test",
+        MaxSize:      4096,
+        MaxAge:       time.Hour,
+        MaxBackups:   100,
+        Level:        INFO,
+        EnableStdout: false,
+        Async:        true,
+        QueueSize:    16,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    for i := 0; i < 100; i++ {
+        logger.Info("async message %d", i)
+    }
+    if err := logger.Sync(); err != nil {
+        t.Fatalf("sync failed: %v", err)
+    }
+
+    content, err := os.ReadFile(filepath.Join(tempDir, "async_test.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    if !strings.Contains(string(content), "async message 99") {
+        t.Error("expected Sync to drain the queue before returning")
+    }
+
+    logger.Info("written before close")
+    if err := logger.Close(); err != nil {
+        t.Fatalf("close failed: %v", err)
+    }
+    content, err = os.ReadFile(filepath.Join(tempDir, "async_test.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    if !strings.Contains(string(content), "written before close") {
+        t.Error("expected Close to drain the queue before returning")
+    }
+    if dropped := logger.Stats().Dropped; dropped != 0 {
+        t.Errorf("expected no dropped records with the block policy, got %d", dropped)
+    }
+
+    // Records logged after Close are counted as dropped, never silently lost
+    for i := 0; i < 10; i++ {
+        logger.Info("after close %d", i)
+    }
+    if dropped := logger.Stats().Dropped; dropped != 10 {
+        t.Errorf("expected 10 dropped records after close, got %d", dropped)
+    }
+}
+
+func TestAsyncOverflowPolicies(t *testing.T) {
+    cases := []struct {
+        name     string
+        policy   OverflowPolicy
+        expected []string
+    }{
+        {"drop_newest", OverflowDropNewest, []string{"m0", "m1", "m2", "e5"}},
+        {"drop_oldest", OverflowDropOldest, []string{"m0", "m3", "m4", "e5"}},
+        {"drop_below_level", OverflowDropBelowLevel, []string{"m0", "m1", "m2", "e5"}},
+    }
+
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            // Create temporary directory
+            tempDir := "./test_logs_overflow_" + tc.name
+            defer os.RemoveAll(tempDir)
+
+            config := &Config{
+                LogDir:         tempDir,
+                FileName:       "overflow_test",
+                MaxSize:        1024 * 1024,
+                Level:          INFO,
+                Async:          true,
+                QueueSize:      2,
+                OverflowPolicy: tc.policy,
+                OverflowLevel:  ERROR,
+            }
+
+            logger, err := NewLogger(config)
+            if err != nil {
+                t.Fatalf("failed to create logger: %v", err)
+            }
+            defer logger.Close()
+
+            sink := &blockingSink{release: make(chan struct{})}
+            if err := logger.AddSink("blocking", sink); err != nil {
+                t.Fatalf("failed to add sink: %v", err)
+            }
+
+            // The writer picks up m0 and blocks in the sink, leaving the queue empty
+            logger.Info("m0")
+            deadline := time.Now().Add(time.Second)
+            for logger.Stats().Queued != 0 && time.Now().Before(deadline) {
+                time.Sleep(time.Millisecond)
+            }
+
+            // m1 and m2 fill the queue, m3 and m4 overflow it
+            for i := 1; i <= 4; i++ {
+                logger.Info("m%d", i)
+            }
+
+            // e5 is logged while the writer drains; with OverflowDropBelowLevel
+            // it may block on the full queue but must never be dropped
+            done := make(chan struct{})
+            go func() {
+                defer close(done)
+                if tc.policy != OverflowDropBelowLevel {
+                    // Wait for room so e5 does not overflow as well
+                    for logger.Stats().Queued != 0 {
+                        time.Sleep(time.Millisecond)
+                    }
+                }
+                logger.Error("e5")
+            }()
+
+            close(sink.release)
+            <-done
+            if err := logger.Sync(); err != nil {
+                t.Fatalf("sync failed: %v", err)
+            }
+
+            if strings.Join(sink.written, ",") != strings.Join(tc.expected, ",") {
+                t.Errorf("expected %v, got %v", tc.expected, sink.written)
+            }
+            if dropped := logger.Stats().Dropped; dropped != 2 {
+                t.Errorf("expected 2 dropped records, got %d", dropped)
+            }
+        })
+    }
+}
@@ -0,0 +1,294 @@
+package logr
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "regexp"
+    "strings"
+    "time"
+)
+
+const (
+    chainStartMessage = "hash chain start" // Message of the record that starts a file's chain
+    chainHashKey      = "hash"
+    chainPrevKey      = "prev"
+)
+
+var (
+    // zeroHash links the first chained record to no predecessor
+    zeroHash = make([]byte, sha256.Size)
+
+    chainTextSuffix = regexp.MustCompile(` hash=([0-9a-f]{64})$`)
+    chainJSONSuffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+    chainPrevField  = regexp.MustCompile(`(?:\bprev=|"prev":")([0-9a-f]{64})`)
+)
+
+// ErrNoChain is returned by Verify when the log files hold no chained record,
+// such as when they were written without HashChain
+var ErrNoChain = errors.New("no hash chain found")
+
+// ChainError reports the first record at which a hash chain breaks
+type ChainError struct {
+    File   string // Log file holding the record
+    Line   int    // 1-based line number of the record
+    Raw    string // The record as written
+    Reason string
+}
+
+// Error implements error
+func (e *ChainError) Error() string {
+    return fmt.Sprintf("%s:%d: hash chain broken: %s", e.File, e.Line, e.Reason)
+}
+
+// initChain restores the chain state from the log directory on startup
+func (l *Logger) initChain() error {
+    files, err := l.getLogFiles()
+    if err != nil {
+        return fmt.Errorf("failed to list log files: %v", err)
+    }
+
+    l.chainPrev = zeroHash
+    for i := len(files) - 1; i >= 0; i-- {
+        last, chained, err := lastChainHash(files[i], l.config.EncryptionKeys)
+        if err != nil {
+            return err
+        }
+        if files[i].active && files[i].size > 0 {
+            // Keep appending to the active file's chain, or start one in it
+            if chained {
+                l.chainHash = last
+            }
+            return nil
+        }
+        if !files[i].active {
+            if chained {
+                l.chainPrev = last
+            }
+            return nil
+        }
+    }
+    return nil
+}
+
+// lastChainHash returns the hash of a log file's last record, and whether it has one
+func lastChainHash(file logFile, keys KeyProvider) ([]byte, bool, error) {
+    reader := &Reader{files: []logFile{file}, keys: keys}
+    defer reader.Close()
+
+    var last string
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            break
+        }
+        if perr, ok := err.(*ParseError); ok {
+            last = perr.Raw
+            continue
+        }
+        if err != nil {
+            return nil, false, err
+        }
+        last = record.Raw
+    }
+
+    _, hash, ok := splitChainHash(last)
+    return hash, ok, nil
+}
+
+// chainOverhead returns the bytes the hash chain adds to an encoded record
+func (l *Logger) chainOverhead() int {
+    if !l.config.HashChain {
+        return 0
+    }
+    if l.chainJSON() {
+        return len(`,"hash":""`) + 2*sha256.Size
+    }
+    return len(" hash=") + 2*sha256.Size
+}
+
+// chainJSON reports whether hashes are attached as a JSON member rather than
+// as a trailing key=value pair, which depends only on the configured encoder
+func (l *Logger) chainJSON() bool {
+    _, ok := l.encoder.(*JSONEncoder)
+    return ok
+}
+
+// chainRecord links an encoded record to the chain, prepending a chain start
+// record if the active file has none yet; the caller must hold l.mu
+func (l *Logger) chainRecord(line []byte, ts time.Time) ([]byte, error) {
+    var out []byte
+    if l.chainHash == nil {
+        header, err := l.encoder.Encode(&Entry{
+            Time:    ts,
+            Level:   INFO,
+            Message: chainStartMessage,
+            Fields:  []Field{String(chainPrevKey, hex.EncodeToString(l.chainPrev))},
+        })
+        if err != nil {
+            return nil, err
+        }
+        out = appendChainHash(out, header, l.chainPrev, l.chainJSON())
+        l.chainHash = chainHashOf(out)
+    }
+    record := appendChainHash(nil, line, l.chainHash, l.chainJSON())
+    l.chainHash = chainHashOf(record)
+    return append(out, record...), nil
+}
+
+// resetChain ends the active file's chain after rotation; the next record
+// starts a new chain linked to the rotated file's final hash
+func (l *Logger) resetChain() {
+    if !l.config.HashChain || l.chainHash == nil {
+        return
+    }
+    l.chainPrev = l.chainHash
+    l.chainHash = nil
+}
+
+// appendChainHash appends line with the hash of prev and line attached, as the
+// last member of a JSON object or as a trailing key=value pair otherwise
+func appendChainHash(dst, line, prev []byte, json bool) []byte {
+    line = bytes.TrimSuffix(line, []byte("\n"))
+    sum := sha256.Sum256(append(append([]byte{}, prev...), line...))
+    hash := hex.EncodeToString(sum[:])
+
+    if json && bytes.HasSuffix(line, []byte("}")) {
+        dst = append(dst, line[:len(line)-1]...)
+        dst = append(dst, `,"`+chainHashKey+`":"`+hash+`"}`...)
+    } else {
+        dst = append(dst, line...)
+        dst = append(dst, " "+chainHashKey+"="+hash...)
+    }
+    return append(dst, '\n')
+}
+
+// chainHashOf returns the hash attached to the last record in buf
+func chainHashOf(buf []byte) []byte {
+    line := strings.TrimSuffix(string(buf), "\n")
+    if i := strings.LastIndexByte(line, '\n'); i >= 0 {
+        line = line[i+1:]
+    }
+    _, hash, _ := splitChainHash(line)
+    return hash
+}
+
+// splitChainHash separates a record from its attached hash
+func splitChainHash(raw string) (string, []byte, bool) {
+    var content string
+    var m []string
+    if m = chainJSONSuffix.FindStringSubmatch(raw); m != nil {
+        content = raw[:len(raw)-len(m[0])] + "}"
+    } else if m = chainTextSuffix.FindStringSubmatch(raw); m != nil {
+        content = raw[:len(raw)-len(m[0])]
+    } else {
+        return raw, nil, false
+    }
+    hash, err := hex.DecodeString(m[1])
+    if err != nil {
+        return raw, nil, false
+    }
+    return content, hash, true
+}
+
+// chainStart returns the predecessor hash declared by a chain start record
+func chainStart(content string) ([]byte, bool) {
+    entry, err := ParseLine(content)
+    if err != nil || !strings.HasPrefix(entry.Message, chainStartMessage) {
+        return nil, false
+    }
+    m := chainPrevField.FindStringSubmatch(content)
+    if m == nil {
+        return nil, false
+    }
+    prev, err := hex.DecodeString(m[1])
+    return prev, err == nil
+}
+
+// Verify checks the hash chain of the log files named name in dir, using the
+// default backup naming template. It returns a *ChainError for the first
+// record where the chain breaks, including gaps left by deleted or truncated
+// files; only the oldest file may link to a file that no longer exists. It
+// returns ErrNoChain if no record is chained.
+func Verify(dir, name string) error {
+    return VerifyFiles(&Config{LogDir: dir, FileName: name})
+}
+
+// VerifyFiles checks the hash chain of the log files of a logger configuration
+func VerifyFiles(config *Config) error {
+    reader, err := OpenReader(config)
+    if err != nil {
+        return err
+    }
+    defer reader.Close()
+
+    var running []byte // Hash of the last chained record, nil before the chain starts
+    var file string
+    firstFile, fileStart := true, false
+    for {
+        var raw string
+        var line int
+        record, err := reader.Next()
+        if err == io.EOF {
+            if running == nil {
+                return ErrNoChain
+            }
+            return nil
+        }
+        if perr, ok := err.(*ParseError); ok {
+            raw, line = perr.Raw, perr.Line
+        } else if err != nil {
+            return err
+        } else {
+            raw, line = record.Raw, record.Line
+        }
+        if reader.File() != file {
+            if file != "" {
+                firstFile = false
+            }
+            file, fileStart = reader.File(), true
+        }
+        broken := func(reason string) error {
+            return &ChainError{File: file, Line: line, Raw: raw, Reason: reason}
+        }
+
+        content, hash, ok := splitChainHash(raw)
+        if !ok {
+            if running != nil {
+                return broken("record has no hash")
+            }
+            // Records written before the chain was enabled
+            fileStart = false
+            continue
+        }
+
+        prev := running
+        if declared, isStart := chainStart(content); isStart {
+            linked := running
+            if linked == nil {
+                linked = zeroHash
+            }
+            switch {
+            case firstFile && fileStart:
+                // The oldest file may link to a backup removed by retention
+            case !bytes.Equal(declared, linked) && fileStart:
+                return broken("does not link to the previous file; a file is missing or was modified")
+            case !bytes.Equal(declared, linked):
+                return broken("chain restarted")
+            }
+            prev = declared
+        } else if running == nil {
+            return broken("records are missing before this one")
+        }
+
+        sum := sha256.Sum256(append(append([]byte{}, prev...), content...))
+        if !bytes.Equal(sum[:], hash) {
+            return broken("hash mismatch; this record was modified or records before it were removed")
+        }
+        running = hash
+        fileStart = false
+    }
+}
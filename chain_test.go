@@ -0,0 +1,163 @@
+package logr
+
+import (
+    "encoding/hex"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestHashChain(t *testing.T) {
+    for name, encoder := range map[string]Encoder{"text": NewTextEncoder(), "json": NewJSONEncoder()} {
+        t.Run(name, func(t *testing.T) {
+            // Create temporary directory
+            tempDir := "./test_logs_hash_chain"
+            defer os.RemoveAll(tempDir)
+
+            config := &Config{
+                LogDir:     tempDir,
+                FileName:   "chain",
+                MaxSize:    600,
+                MaxAge:     time.Hour,
+                MaxBackups: 100,
+                Level:      DEBUG,
+                Encoder:    encoder,
+                HashChain:  true,
+            }
+
+            // The chain continues across restarts and rotations
+            for run := 0; run < 2; run++ {
+                logger, err := NewLogger(config)
+                if err != nil {
+                    t.Fatalf("failed to create logger: %v", err)
+                }
+                for i := 0; i < 20; i++ {
+                    logger.Infow("audit record", Int("run", run), Int("i", i))
+                }
+                logger.Close()
+            }
+
+            if err := Verify(tempDir, "chain"); err != nil {
+                t.Fatalf("expected an intact chain, got %v", err)
+            }
+
+            files, err := ListLogFiles(config)
+            if err != nil {
+                t.Fatalf("failed to list log files: %v", err)
+            }
+            if len(files) < 4 {
+                t.Fatalf("expected several rotated files, got %d", len(files))
+            }
+
+            // Editing a record breaks the chain at that record
+            target := files[1].Path
+            original, _ := os.ReadFile(target)
+            lines := strings.Split(string(original), "\n")
+            lines[2] = strings.Replace(lines[2], "audit", "AUDIT", 1)
+            os.WriteFile(target, []byte(strings.Join(lines, "\n")), 0644)
+
+            var chainErr *ChainError
+            err = Verify(tempDir, "chain")
+            if !errors.As(err, &chainErr) || chainErr.File != target || chainErr.Line != 3 {
+                t.Errorf("expected a break at %s:3, got %v", target, err)
+            }
+
+            // Removing a file breaks the chain at the start of the next one
+            os.WriteFile(target, original, 0644)
+            os.Remove(target)
+            err = Verify(tempDir, "chain")
+            if !errors.As(err, &chainErr) || chainErr.File != files[2].Path || chainErr.Line != 1 {
+                t.Errorf("expected a break at %s:1, got %v", files[2].Path, err)
+            }
+
+            // The oldest remaining file may link to a backup removed by retention
+            os.Remove(files[0].Path)
+            os.Remove(files[2].Path)
+            if err := Verify(tempDir, "chain"); err != nil {
+                t.Errorf("expected retention to keep the chain intact, got %v", err)
+            }
+            if _, err := os.Stat(filepath.Join(tempDir, "chain.log")); err != nil {
+                t.Errorf("active file missing: %v", err)
+            }
+        })
+    }
+}
+
+func TestHashChainRecordsEndingInBrace(t *testing.T) {
+    for name, encoder := range map[string]Encoder{"text": NewTextEncoder(), "logfmt": NewLogfmtEncoder()} {
+        t.Run(name, func(t *testing.T) {
+            // Create temporary directory
+            tempDir := "./test_logs_hash_chain_brace"
+            defer os.RemoveAll(tempDir)
+
+            config := &Config{
+                LogDir:     tempDir,
+                FileName:   "chain",
+                MaxSize:    1024 * 1024,
+                MaxBackups: 100,
+                Level:      DEBUG,
+                Encoder:    encoder,
+                HashChain:  true,
+            }
+            logger, err := NewLogger(config)
+            if err != nil {
+                t.Fatalf("failed to create logger: %v", err)
+            }
+            logger.Info("query {select 1}")
+            logger.Infow("query", String("sql", "{select:2}"))
+            logger.Infow("nested {a {b}}", String("obj", "{k:v}"))
+            logger.Close()
+
+            if err := Verify(tempDir, "chain"); err != nil {
+                t.Fatalf("expected an intact chain, got %v", err)
+            }
+
+            reader, err := OpenReader(config)
+            if err != nil {
+                t.Fatalf("failed to open reader: %v", err)
+            }
+            defer reader.Close()
+
+            // Records read back exactly as encoded, with the hash after their last brace
+            expected := []*Entry{
+                {Level: INFO, Message: chainStartMessage, Fields: []Field{String(chainPrevKey, hex.EncodeToString(zeroHash))}},
+                {Level: INFO, Message: "query {select 1}"},
+                {Level: INFO, Message: "query", Fields: []Field{String("sql", "{select:2}")}},
+                {Level: INFO, Message: "nested {a {b}}", Fields: []Field{String("obj", "{k:v}")}},
+            }
+            for i := 0; ; i++ {
+                record, err := reader.Next()
+                if err != nil {
+                    if i != len(expected) {
+                        t.Errorf("expected %d records, got %d: %v", len(expected), i, err)
+                    }
+                    break
+                }
+                content, _, ok := splitChainHash(record.Raw)
+                if !ok || !strings.Contains(record.Raw, " hash=") || strings.Contains(record.Raw, `"hash":`) {
+                    t.Errorf("expected a trailing hash=, got %q", record.Raw)
+                }
+                if i >= len(expected) {
+                    continue
+                }
+                expected[i].Time = record.Time
+                line, _ := encoder.Encode(expected[i])
+                if content != strings.TrimSuffix(string(line), "\n") {
+                    t.Errorf("expected record %q, got %q", line, content)
+                }
+            }
+
+            // Editing a record ending in a brace still breaks the chain
+            path := filepath.Join(tempDir, "chain.log")
+            content, _ := os.ReadFile(path)
+            os.WriteFile(path, []byte(strings.Replace(string(content), "select 1", "select 9", 1)), 0644)
+            var chainErr *ChainError
+            if err := Verify(tempDir, "chain"); !errors.As(err, &chainErr) || chainErr.Line != 2 {
+                t.Errorf("expected a break at line 2, got %v", err)
+            }
+        })
+    }
+}
@@ -0,0 +1,427 @@
+// Command logr inspects the log directories written by the logr package.
+//
+// Usage:
+//
+//     logr <command> [flags] [args]
+//
+// Commands:
+//
+//     cat     print every record, oldest first, across backups and the active file
+//     tail    print the last records, and with -f follow new ones across rotations
+//     grep    print the records matching a regular expression
+//     stats   summarize records per level and per file
+//     ls      list the active file and the backups with sizes and time ranges
+//     verify  check the hash chain, and with -key the backup signatures
+//
+// Every command accepts -dir, -name and -template to locate the log files,
+// and -enc-key to decrypt encrypted ones.
+package main
+
+import (
+    "encoding/hex"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "os/signal"
+    "regexp"
+    "sort"
+    "strings"
+    "text/tabwriter"
+    "time"
+
+    "gopkg.in/taichidb/logr.v1"
+)
+
+const timeFormat = "2006-01-02 15:04:05.000"
+
+// command is a logr subcommand
+type command struct {
+    name  string
+    usage string
+    run   func(config *logr.Config, flags *flag.FlagSet, args []string) error
+    flags func(flags *flag.FlagSet)
+}
+
+var commands []*command
+
+// Output of the commands, replaced by tests
+var (
+    stdout io.Writer = os.Stdout
+    stderr io.Writer = os.Stderr
+)
+
+// errUsage reports invalid arguments, after the usage was printed
+var errUsage = errors.New("invalid arguments")
+
+// errFailed reports a check that failed, after the failures were printed
+var errFailed = errors.New("check failed")
+
+func main() {
+    os.Exit(run(os.Args[1:]))
+}
+
+// run runs the command named by args[0] and returns the exit status
+func run(args []string) int {
+    commands = []*command{
+        {name: "cat", usage: "cat", run: runCat},
+        {name: "tail", usage: "tail [-n lines] [-f]", run: runTail, flags: tailFlags},
+        {name: "grep", usage: "grep [-level LEVEL] [-since T] [-until T] pattern", run: runGrep, flags: grepFlags},
+        {name: "stats", usage: "stats", run: runStats},
+        {name: "ls", usage: "ls", run: runLs},
+        {name: "verify", usage: "verify [-key id=hexkey]...", run: runVerify, flags: verifyFlags},
+    }
+
+    if len(args) < 1 {
+        usage()
+        return 2
+    }
+    for _, cmd := range commands {
+        if cmd.name != args[0] {
+            continue
+        }
+
+        flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+        flags.SetOutput(stderr)
+        config := logr.DefaultConfig()
+        flags.StringVar(&config.LogDir, "dir", config.LogDir, "log directory")
+        flags.StringVar(&config.FileName, "name", config.FileName, "log file name prefix")
+        flags.StringVar(&config.BackupNameTemplate, "template", "", "backup name template")
+        decryptKeys := &logr.Keyring{Keys: map[string][]byte{}}
+        flags.Func("enc-key", "encryption key as id=hexkey; repeat for rotated keys", keyFlag(decryptKeys))
+        if cmd.flags != nil {
+            cmd.flags(flags)
+        }
+        flags.Usage = func() {
+            fmt.Fprintf(stderr, "usage: logr %s\n", cmd.usage)
+            flags.PrintDefaults()
+        }
+        if err := flags.Parse(args[1:]); err != nil {
+            return 2
+        }
+        if len(decryptKeys.Keys) > 0 {
+            config.EncryptionKeys = decryptKeys
+        }
+
+        switch err := cmd.run(config, flags, flags.Args()); err {
+        case nil:
+            return 0
+        case errUsage:
+            return 2
+        case errFailed:
+            return 1
+        default:
+            fmt.Fprintf(stderr, "logr %s: %v\n", cmd.name, err)
+            return 1
+        }
+    }
+
+    usage()
+    return 2
+}
+
+func usage() {
+    fmt.Fprintln(stderr, "usage: logr <command> [-dir dir] [-name name] [-enc-key id=hexkey] [flags] [args]")
+    fmt.Fprintln(stderr, "commands:")
+    for _, cmd := range commands {
+        fmt.Fprintf(stderr, "    %s\n", cmd.usage)
+    }
+}
+
+// forEach calls fn for every line of the log files in range, parsed or not
+func forEach(config *logr.Config, from, to time.Time, fn func(record *logr.Record, parsed bool)) error {
+    reader, err := logr.OpenReader(config)
+    if err != nil {
+        return err
+    }
+    defer reader.Close()
+    reader.SetRange(from, to)
+
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        }
+        var perr *logr.ParseError
+        if errors.As(err, &perr) {
+            fn(&logr.Record{Entry: logr.Entry{Message: perr.Raw}, File: perr.File, Line: perr.Line, Raw: perr.Raw}, false)
+            continue
+        }
+        if err != nil {
+            return err
+        }
+        fn(record, true)
+    }
+}
+
+func runCat(config *logr.Config, flags *flag.FlagSet, args []string) error {
+    return forEach(config, time.Time{}, time.Time{}, func(record *logr.Record, parsed bool) {
+        fmt.Fprintln(stdout, record.Raw)
+    })
+}
+
+var (
+    tailLines  int
+    tailFollow bool
+)
+
+func tailFlags(flags *flag.FlagSet) {
+    flags.IntVar(&tailLines, "n", 10, "number of records to print")
+    flags.BoolVar(&tailFollow, "f", false, "follow new records across rotations")
+}
+
+func runTail(config *logr.Config, flags *flag.FlagSet, args []string) error {
+    if tailFollow {
+        follower, err := logr.Follow(config, logr.FollowOptions{Lines: tailLines}, func(record *logr.Record) {
+            fmt.Fprintln(stdout, record.Raw)
+        })
+        if err != nil {
+            return err
+        }
+        interrupt := make(chan os.Signal, 1)
+        signal.Notify(interrupt, os.Interrupt)
+        <-interrupt
+        return follower.Close()
+    }
+
+    var last []string
+    err := forEach(config, time.Time{}, time.Time{}, func(record *logr.Record, parsed bool) {
+        last = append(last, record.Raw)
+        if len(last) > tailLines {
+            last = last[1:]
+        }
+    })
+    for _, raw := range last {
+        fmt.Fprintln(stdout, raw)
+    }
+    return err
+}
+
+var (
+    grepLevel string
+    grepSince string
+    grepUntil string
+)
+
+func grepFlags(flags *flag.FlagSet) {
+    flags.StringVar(&grepLevel, "level", "", "minimum level, e.g. ERROR")
+    flags.StringVar(&grepSince, "since", "", "start time, as a duration ago (1h) or RFC 3339")
+    flags.StringVar(&grepUntil, "until", "", "end time, as a duration ago (10m) or RFC 3339")
+}
+
+func runGrep(config *logr.Config, flags *flag.FlagSet, args []string) error {
+    if len(args) != 1 {
+        flags.Usage()
+        return errUsage
+    }
+    pattern, err := regexp.Compile(args[0])
+    if err != nil {
+        return err
+    }
+
+    level := logr.DEBUG
+    if grepLevel != "" {
+        if level, err = logr.ParseLevel(grepLevel); err != nil {
+            return err
+        }
+    }
+    from, err := parseTime(grepSince)
+    if err != nil {
+        return err
+    }
+    to, err := parseTime(grepUntil)
+    if err != nil {
+        return err
+    }
+
+    return forEach(config, from, to, func(record *logr.Record, parsed bool) {
+        if parsed && record.Level >= level && pattern.MatchString(record.Raw) {
+            fmt.Fprintln(stdout, record.Raw)
+        }
+    })
+}
+
+// parseTime parses a time given as a duration before now or in RFC 3339 format
+func parseTime(s string) (time.Time, error) {
+    if s == "" {
+        return time.Time{}, nil
+    }
+    if d, err := time.ParseDuration(s); err == nil {
+        return time.Now().Add(-d), nil
+    }
+    t, err := time.Parse(time.RFC3339, s)
+    if err != nil {
+        return t, fmt.Errorf("invalid time %q: want a duration such as 1h or an RFC 3339 time", s)
+    }
+    return t, nil
+}
+
+// fileStats holds the statistics of one log file
+type fileStats struct {
+    records int
+    bytes   int64
+}
+
+func runStats(config *logr.Config, flags *flag.FlagSet, args []string) error {
+    files, err := logr.ListLogFiles(config)
+    if err != nil {
+        return err
+    }
+
+    levels := map[logr.LogLevel]int{}
+    perFile := map[string]*fileStats{}
+    var first, last time.Time
+    var records, unparsed int
+    err = forEach(config, time.Time{}, time.Time{}, func(record *logr.Record, parsed bool) {
+        stats := perFile[record.File]
+        if stats == nil {
+            stats = &fileStats{}
+            perFile[record.File] = stats
+        }
+        stats.records++
+        stats.bytes += int64(len(record.Raw)) + 1
+        if !parsed {
+            unparsed++
+            return
+        }
+        records++
+        levels[record.Level]++
+        if first.IsZero() || record.Time.Before(first) {
+            first = record.Time
+        }
+        if record.Time.After(last) {
+            last = record.Time
+        }
+    })
+    if err != nil {
+        return err
+    }
+
+    var total int64
+    for _, file := range files {
+        total += file.Size
+    }
+
+    fmt.Fprintf(stdout, "files:    %d\n", len(files))
+    fmt.Fprintf(stdout, "bytes:    %d\n", total)
+    fmt.Fprintf(stdout, "records:  %d\n", records)
+    if unparsed > 0 {
+        fmt.Fprintf(stdout, "unparsed: %d\n", unparsed)
+    }
+    if records > 0 {
+        fmt.Fprintf(stdout, "from:     %s\n", first.Format(timeFormat))
+        fmt.Fprintf(stdout, "to:       %s\n", last.Format(timeFormat))
+        fmt.Fprintf(stdout, "span:     %s\n", last.Sub(first).Round(time.Millisecond))
+    }
+
+    fmt.Fprintln(stdout)
+    var names []logr.LogLevel
+    for level := range levels {
+        names = append(names, level)
+    }
+    sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+    for _, level := range names {
+        fmt.Fprintf(stdout, "%-8s %d\n", level, levels[level])
+    }
+
+    fmt.Fprintln(stdout)
+    w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(w, "FILE\tRECORDS\tBYTES\tUNCOMPRESSED")
+    for _, file := range files {
+        stats := perFile[file.Path]
+        if stats == nil {
+            stats = &fileStats{}
+        }
+        fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", file.Name, stats.records, file.Size, stats.bytes)
+    }
+    return w.Flush()
+}
+
+func runLs(config *logr.Config, flags *flag.FlagSet, args []string) error {
+    files, err := logr.ListLogFiles(config)
+    if err != nil {
+        return err
+    }
+
+    w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+    fmt.Fprintln(w, "NAME\tSEQ\tSIZE\tFIRST\tLAST")
+    for _, file := range files {
+        seq := fmt.Sprint(file.Seq)
+        if file.Active {
+            seq = "active"
+        }
+        first, last, err := file.TimeRange()
+        span := []string{"-", "-"}
+        if err != nil {
+            span[0] = "error: " + err.Error()
+        } else if !first.IsZero() {
+            span = []string{first.Format(timeFormat), last.Format(timeFormat)}
+        }
+        fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", file.Name, seq, file.Size, strings.Join(span, "\t"))
+    }
+    return w.Flush()
+}
+
+// verifyKeys is the keyring given with -key
+var verifyKeys *logr.Keyring
+
+func verifyFlags(flags *flag.FlagSet) {
+    verifyKeys = &logr.Keyring{Keys: map[string][]byte{}}
+    flags.Func("key", "signing key as id=hexkey; repeat for rotated keys", keyFlag(verifyKeys))
+}
+
+// keyFlag returns a flag function that adds keys given as id=hexkey to keyring
+func keyFlag(keyring *logr.Keyring) func(string) error {
+    return func(s string) error {
+        eq := strings.IndexByte(s, '=')
+        if eq <= 0 {
+            return errors.New("want id=hexkey")
+        }
+        key, err := hex.DecodeString(s[eq+1:])
+        if err != nil {
+            return fmt.Errorf("invalid hex key: %v", err)
+        }
+        keyring.Keys[s[:eq]] = key
+        return nil
+    }
+}
+
+func runVerify(config *logr.Config, flags *flag.FlagSet, args []string) error {
+    failed := false
+    err := logr.VerifyFiles(config)
+    var chainErr *logr.ChainError
+    switch {
+    case errors.As(err, &chainErr):
+        fmt.Fprintln(stdout, chainErr.Error())
+        fmt.Fprintln(stdout, chainErr.Raw)
+        failed = true
+    case err == logr.ErrNoChain:
+        fmt.Fprintln(stdout, "no hash chain found")
+    case err != nil:
+        return err
+    default:
+        fmt.Fprintln(stdout, "hash chain intact")
+    }
+
+    if len(verifyKeys.Keys) > 0 {
+        results, err := logr.VerifySignatures(config, verifyKeys)
+        if err != nil {
+            return err
+        }
+        for _, result := range results {
+            if result.Err != nil {
+                fmt.Fprintf(stdout, "%s: %v\n", result.Path, result.Err)
+                failed = true
+            }
+        }
+        if !failed {
+            fmt.Fprintf(stdout, "%d backup signatures valid\n", len(results))
+        }
+    }
+
+    if failed {
+        return errFailed
+    }
+    return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "strings"
+    "testing"
+    "time"
+
+    "gopkg.in/taichidb/logr.v1"
+)
+
+// runCommand runs the CLI with args and returns its exit status and output
+func runCommand(args ...string) (int, string, string) {
+    var out, errOut bytes.Buffer
+    stdout, stderr = &out, &errOut
+    defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+    code := run(args)
+    return code, out.String(), errOut.String()
+}
+
+func TestParseTime(t *testing.T) {
+    tests := []struct {
+        in      string
+        want    time.Time
+        ago     time.Duration
+        wantErr bool
+    }{
+        {in: ""},
+        {in: "1h", ago: time.Hour},
+        {in: "90s", ago: 90 * time.Second},
+        {in: "2024-01-02T03:04:05Z", want: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+        {in: "2024-01-02 03:04:05", wantErr: true},
+        {in: "yesterday", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        got, err := parseTime(tt.in)
+        if tt.wantErr {
+            if err == nil {
+                t.Errorf("parseTime(%q): expected an error, got %v", tt.in, got)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("parseTime(%q): unexpected error: %v", tt.in, err)
+            continue
+        }
+        if tt.ago != 0 {
+            if d := time.Since(got) - tt.ago; d < 0 || d > time.Minute {
+                t.Errorf("parseTime(%q): expected %v ago, got %v", tt.in, tt.ago, got)
+            }
+            continue
+        }
+        if !got.Equal(tt.want) {
+            t.Errorf("parseTime(%q): expected %v, got %v", tt.in, tt.want, got)
+        }
+    }
+}
+
+func TestKeyFlag(t *testing.T) {
+    tests := []struct {
+        in      string
+        id      string
+        key     string
+        wantErr bool
+    }{
+        {in: "k1=00ff", id: "k1", key: "\x00\xff"},
+        {in: "a=b=00", wantErr: true},
+        {in: "k2=", id: "k2", key: ""},
+        {in: "00ff", wantErr: true},
+        {in: "=00ff", wantErr: true},
+        {in: "k3=zz", wantErr: true},
+        {in: "k4=abc", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        keyring := &logr.Keyring{Keys: map[string][]byte{}}
+        err := keyFlag(keyring)(tt.in)
+        if tt.wantErr {
+            if err == nil {
+                t.Errorf("keyFlag(%q): expected an error, got %v", tt.in, keyring.Keys)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("keyFlag(%q): unexpected error: %v", tt.in, err)
+            continue
+        }
+        if key, ok := keyring.Keys[tt.id]; !ok || string(key) != tt.key || len(keyring.Keys) != 1 {
+            t.Errorf("keyFlag(%q): unexpected keys %v", tt.in, keyring.Keys)
+        }
+    }
+}
+
+// writeFixture writes a log directory with records at several levels
+func writeFixture(t *testing.T, dir string, hashChain bool) {
+    logger, err := logr.NewLogger(&logr.Config{
+        LogDir:     dir,
+        FileName:   "cli",
+        MaxSize:    1024 * 1024,
+        MaxBackups: 10,
+        Level:      logr.DEBUG,
+        HashChain:  hashChain,
+    })
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Debug("cache warm")
+    logger.Info("request served")
+    logger.Error("request failed")
+    logger.Warn("request slow")
+    logger.Error("disk failed")
+    if err := logger.Close(); err != nil {
+        t.Fatalf("failed to close logger: %v", err)
+    }
+}
+
+func TestGrepAndStats(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_cli"
+    defer os.RemoveAll(tempDir)
+    writeFixture(t, tempDir, false)
+
+    code, out, errOut := runCommand("grep", "-dir", tempDir, "-name", "cli", "-level", "ERROR", "failed")
+    if code != 0 {
+        t.Fatalf("grep exited with %d: %s", code, errOut)
+    }
+    lines := strings.Split(strings.TrimSpace(out), "\n")
+    if len(lines) != 2 || !strings.Contains(lines[0], "request failed") || !strings.Contains(lines[1], "disk failed") {
+        t.Errorf("unexpected grep output:\n%s", out)
+    }
+
+    code, out, _ = runCommand("grep", "-dir", tempDir, "-name", "cli", "request")
+    if code != 0 || strings.Count(out, "\n") != 3 {
+        t.Errorf("expected 3 records at any level, got %d:\n%s", code, out)
+    }
+
+    code, _, errOut = runCommand("grep", "-dir", tempDir, "-name", "cli")
+    if code != 2 || !strings.Contains(errOut, "usage: logr grep") {
+        t.Errorf("expected a usage error without pattern, got %d: %s", code, errOut)
+    }
+    code, _, errOut = runCommand("grep", "-dir", tempDir, "-name", "cli", "-level", "LOUD", "x")
+    if code != 1 || errOut == "" {
+        t.Errorf("expected an error for an unknown level, got %d: %s", code, errOut)
+    }
+
+    code, out, errOut = runCommand("stats", "-dir", tempDir, "-name", "cli")
+    if code != 0 {
+        t.Fatalf("stats exited with %d: %s", code, errOut)
+    }
+    for _, want := range []string{"files:    1\n", "records:  5\n", "DEBUG    1\n", "INFO     1\n", "WARN     1\n", "ERROR    2\n"} {
+        if !strings.Contains(out, want) {
+            t.Errorf("expected %q in stats output:\n%s", want, out)
+        }
+    }
+    if strings.Contains(out, "unparsed") {
+        t.Errorf("expected every record to parse:\n%s", out)
+    }
+
+    code, _, errOut = runCommand("frobnicate")
+    if code != 2 || !strings.Contains(errOut, "commands:") {
+        t.Errorf("expected the usage for an unknown command, got %d: %s", code, errOut)
+    }
+}
+
+func TestVerify(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_cli_verify"
+    defer os.RemoveAll(tempDir)
+
+    writeFixture(t, tempDir+"/plain", false)
+    code, out, errOut := runCommand("verify", "-dir", tempDir+"/plain", "-name", "cli")
+    if code != 0 || out != "no hash chain found\n" {
+        t.Errorf("expected no chain to be reported, got %d: %q %s", code, out, errOut)
+    }
+
+    writeFixture(t, tempDir+"/chained", true)
+    code, out, errOut = runCommand("verify", "-dir", tempDir+"/chained", "-name", "cli")
+    if code != 0 || out != "hash chain intact\n" {
+        t.Errorf("expected an intact chain, got %d: %q %s", code, out, errOut)
+    }
+}
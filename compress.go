@@ -0,0 +1,207 @@
+package logr
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "sync"
+    "time"
+)
+
+// compressWaitTimeout is how long Close waits for in-flight compressions
+const compressWaitTimeout = 30 * time.Second
+
+// compressJob is a rotated file waiting to be compressed, encrypted and/or signed
+type compressJob struct {
+    src string // Uncompressed backup
+    dst string // Final .log.gz path, empty if the backup is not compressed
+}
+
+// startCompressors starts the bounded pool of background compression, encryption and signing workers
+func (l *Logger) startCompressors() {
+    workers := l.config.CompressWorkers
+    if workers <= 0 {
+        workers = 1
+    }
+    l.compressCond = sync.NewCond(&l.compressMu)
+    for i := 0; i < workers; i++ {
+        l.compressWG.Add(1)
+        go l.compressRoutine()
+    }
+}
+
+// enqueueCompression hands a rotated file to the background compressors.
+// It never blocks, as rotation calls it with l.mu held: the pending list is
+// unbounded. Files left uncompressed after Close are picked up again on the
+// next start.
+func (l *Logger) enqueueCompression(src, dst string) {
+    if l.compressCond == nil {
+        return
+    }
+    l.journalBackup(src, src)
+    l.compressMu.Lock()
+    defer l.compressMu.Unlock()
+    if l.compressClosed {
+        return
+    }
+    l.compressPending = append(l.compressPending, compressJob{src: src, dst: dst})
+    l.compressCond.Signal()
+}
+
+// nextCompressJob waits for a pending job. It returns false once the workers
+// are stopped and no job is left.
+func (l *Logger) nextCompressJob() (compressJob, bool) {
+    l.compressMu.Lock()
+    defer l.compressMu.Unlock()
+    for len(l.compressPending) == 0 && !l.compressClosed {
+        l.compressCond.Wait()
+    }
+    if len(l.compressPending) == 0 {
+        return compressJob{}, false
+    }
+    job := l.compressPending[0]
+    l.compressPending[0] = compressJob{}
+    l.compressPending = l.compressPending[1:]
+    return job, true
+}
+
+// enqueueBackup hands a rotated file that is not compressed to the background
+// workers for encryption and signing
+func (l *Logger) enqueueBackup(path string) {
+    l.enqueueCompression(path, "")
+}
+
+// compressRoutine is a background compression worker
+func (l *Logger) compressRoutine() {
+    defer l.compressWG.Done()
+
+    for {
+        job, ok := l.nextCompressJob()
+        if !ok {
+            return
+        }
+        // Retention may have deleted the backup while it was queued
+        if _, err := os.Stat(job.src); os.IsNotExist(err) {
+            l.unjournalBackup(job.src)
+            continue
+        }
+        final := job.src
+        if job.dst != "" {
+            if err := compressFile(job.src, job.dst, l.config.EncryptionKeys); err != nil {
+                fmt.Fprintf(diagnostics, "failed to compress log file %s: %v\n", job.src, err)
+                final = ""
+            } else {
+                final = job.dst
+                os.Remove(job.src + SignatureExt)
+            }
+        } else if keys := l.config.EncryptionKeys; keys != nil && !isEncryptedPath(final) {
+            if err := encryptFile(final, final, keys); err != nil {
+                fmt.Fprintf(diagnostics, "failed to encrypt log file %s: %v\n", final, err)
+                final = ""
+            } else {
+                // A manifest of the plaintext no longer matches
+                os.Remove(final + SignatureExt)
+            }
+        }
+        if keys := l.config.SigningKeys; keys != nil && final != "" {
+            if err := signFile(final, keys, l.config.EncryptionKeys); err != nil {
+                fmt.Fprintf(diagnostics, "failed to sign log file %s: %v\n", final, err)
+            } else {
+                l.unjournalBackup(job.src)
+            }
+        }
+
+        // Apply retention now that the archive's compressed size is known.
+        // The cleanup goroutine takes l.mu, so that workers never wait for
+        // a writer that may be waiting for them.
+        l.requestCleanup()
+    }
+}
+
+// stopCompressors stops accepting compression jobs; the workers finish the
+// pending ones
+func (l *Logger) stopCompressors() {
+    if l.compressCond == nil {
+        return
+    }
+    l.compressMu.Lock()
+    defer l.compressMu.Unlock()
+    l.compressClosed = true
+    l.compressCond.Broadcast()
+}
+
+// waitCompressors waits for queued and in-flight compressions to finish
+func (l *Logger) waitCompressors(timeout time.Duration) {
+    done := make(chan struct{})
+    go func() {
+        l.compressWG.Wait()
+        close(done)
+    }()
+
+    select {
+    case <-done:
+    case <-time.After(timeout):
+        fmt.Fprintf(diagnostics, "Warning: timed out waiting for log compression to finish\n")
+    }
+}
+
+// compressFile compresses a log file to an indexed gzip archive, decrypting an
+// encrypted source and, if keys is not nil, encrypting the archive. The archive
+// is written to a temporary file and atomically renamed to dstPath before the
+// source file is removed, so a partially written archive never has the final name.
+func compressFile(srcPath, dstPath string, keys KeyProvider) error {
+    // Open source file
+    srcFile, err := os.Open(srcPath)
+    if err != nil {
+        return fmt.Errorf("failed to open source file: %v", err)
+    }
+    defer srcFile.Close()
+
+    // Create temporary gzip file
+    tmpPath := dstPath + ".tmp"
+    dstFile, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+    if err != nil {
+        return fmt.Errorf("failed to create gzip file: %v", err)
+    }
+
+    // Compress file content with a time index for seeking
+    openSource := func() (io.Reader, error) {
+        if _, err := srcFile.Seek(0, io.SeekStart); err != nil {
+            return nil, err
+        }
+        return openPlaintext(srcFile, keys)
+    }
+    if err := compressIndexed(openSource, dstFile); err != nil {
+        dstFile.Close()
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to compress file content: %v", err)
+    }
+    if err := dstFile.Sync(); err != nil {
+        dstFile.Close()
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to sync gzip file: %v", err)
+    }
+    if err := dstFile.Close(); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to close gzip file: %v", err)
+    }
+
+    // Publish the archive under its final name, encrypted if enabled
+    if keys != nil {
+        err := encryptFile(tmpPath, dstPath, keys)
+        os.Remove(tmpPath)
+        if err != nil {
+            return err
+        }
+    } else if err := os.Rename(tmpPath, dstPath); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to rename gzip file: %v", err)
+    }
+
+    // Remove the original uncompressed file
+    srcFile.Close()
+    if err := os.Remove(srcPath); err != nil && !os.IsNotExist(err) {
+        return fmt.Errorf("failed to remove original log file: %v", err)
+    }
+    return nil
+}
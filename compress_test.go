@@ -0,0 +1,192 @@
+package logr
+
+import (
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestBackgroundCompression(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_background_compress"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:          tempDir,
+        FileName:        "bgz",
+        MaxSize:         512,
+        MaxAge:          time.Hour,
+        MaxBackups:      1000,
+        Level:           INFO,
+        Compress:        true,
+        CompressWorkers: 2,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    const records = 200
+    for i := 0; i < records; i++ {
+        logger.Info("background compression record %d", i)
+    }
+
+    // Close waits for in-flight compressions
+    if err := logger.Close(); err != nil {
+        t.Fatalf("close failed: %v", err)
+    }
+
+    entries, err := os.ReadDir(tempDir)
+    if err != nil {
+        t.Fatalf("failed to read directory: %v", err)
+    }
+
+    lines := 0
+    archives := 0
+    for _, entry := range entries {
+        name := entry.Name()
+        path := tempDir + "/" + name
+        switch {
+        case name == "bgz.log":
+            content, err := os.ReadFile(path)
+            if err != nil {
+                t.Fatalf("failed to read active file: %v", err)
+            }
+            lines += strings.Count(string(content), "\n")
+        case strings.HasSuffix(name, ".log.gz"):
+            archives++
+            f, err := os.Open(path)
+            if err != nil {
+                t.Fatalf("failed to open archive: %v", err)
+            }
+            zr, err := gzip.NewReader(f)
+            if err != nil {
+                t.Fatalf("invalid archive %s: %v", name, err)
+            }
+            content, err := io.ReadAll(zr)
+            if err != nil {
+                t.Fatalf("truncated archive %s: %v", name, err)
+            }
+            f.Close()
+            lines += strings.Count(string(content), "\n")
+        default:
+            t.Errorf("unexpected file left behind: %s", name)
+        }
+    }
+
+    if archives == 0 {
+        t.Error("expected compressed backups")
+    }
+    if lines != records {
+        t.Errorf("expected %d records across all files, got %d", records, lines)
+    }
+}
+
+func TestEnqueueCompressionNeverBlocks(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_compress_enqueue"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := NewLogger(&Config{LogDir: tempDir, FileName: "enqueue", MaxSize: 1024 * 1024, Level: INFO, Compress: true})
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    // Far more jobs than workers can take, queued with l.mu held as rotation
+    // does
+    src := tempDir + "/backup.log"
+    if err := os.WriteFile(src, []byte("record\n"), 0644); err != nil {
+        t.Fatalf("failed to write backup: %v", err)
+    }
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        logger.mu.Lock()
+        defer logger.mu.Unlock()
+        for i := 0; i < 500; i++ {
+            logger.enqueueCompression(src, src+".gz")
+        }
+    }()
+    select {
+    case <-done:
+    case <-time.After(10 * time.Second):
+        // Closing would wait for l.mu forever
+        t.Fatal("enqueueCompression blocked with l.mu held")
+    }
+    logger.Close()
+}
+
+func TestCompressionUnderRotationStorm(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_compress_storm"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := NewLogger(&Config{LogDir: tempDir, FileName: "storm", MaxSize: 64, Level: INFO, Compress: true})
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    // Every record rotates, so writers hold l.mu while workers finish jobs
+    // and request retention
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for i := 0; i < 500; i++ {
+            logger.Info("record %d", i)
+        }
+    }()
+    select {
+    case <-done:
+    case <-time.After(60 * time.Second):
+        // Closing would wait for l.mu forever
+        t.Fatal("logging deadlocked with compression")
+    }
+    logger.Close()
+
+    // Every backup was compressed before Close returned
+    if backups, _ := filepath.Glob(filepath.Join(tempDir, "storm_*.log")); len(backups) != 0 {
+        t.Errorf("expected every backup to be compressed, %d were not", len(backups))
+    }
+}
+
+func TestCompressionWithLockHeld(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_compress_locked"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := NewLogger(&Config{LogDir: tempDir, FileName: "locked", MaxSize: 1024 * 1024, Level: INFO, Compress: true})
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    // A worker applying retention after a job must not wait for l.mu, which
+    // a writer enqueueing more jobs may hold
+    var archives []string
+    logger.mu.Lock()
+    for i := 0; i < 2; i++ {
+        src := filepath.Join(tempDir, fmt.Sprintf("locked_%d.log", i))
+        if err := os.WriteFile(src, []byte("record\n"), 0644); err != nil {
+            t.Fatalf("failed to write backup: %v", err)
+        }
+        logger.enqueueCompression(src, src+".gz")
+        archives = append(archives, src+".gz")
+    }
+    deadline := time.Now().Add(10 * time.Second)
+    for _, archive := range archives {
+        for _, err := os.Stat(archive); err != nil; _, err = os.Stat(archive) {
+            if time.Now().After(deadline) {
+                // Closing would wait for l.mu forever
+                t.Fatalf("%s was not compressed with l.mu held", archive)
+            }
+            time.Sleep(10 * time.Millisecond)
+        }
+    }
+    logger.mu.Unlock()
+    logger.Close()
+}
@@ -0,0 +1,187 @@
+package logr
+
+import (
+    "fmt"
+    "sync/atomic"
+    "time"
+)
+
+const defaultDiskCheckInterval = 10 * time.Second
+
+// diskFree reports free space on a filesystem; replaced in tests
+var diskFree = freeDiskSpace
+
+// DiskEvent describes a change of the disk guard state
+type DiskEvent struct {
+    Degraded bool   // Whether the logger entered (true) or left (false) degraded mode
+    Free     uint64 // Free bytes on LogDir's filesystem when the state changed
+    Pruned   int    // Backups deleted to recover space before degrading
+}
+
+// startDiskGuard starts the free space monitor if a low watermark is configured
+func (l *Logger) startDiskGuard() {
+    if _, err := diskFree(l.config.LogDir); err != nil {
+        fmt.Fprintf(diagnostics, "Warning: disk space guard disabled: %v\n", err)
+        return
+    }
+    l.diskCheck = make(chan struct{}, 1)
+    l.checkDisk()
+    go l.diskRoutine()
+}
+
+// diskRoutine is the goroutine that periodically checks free disk space
+func (l *Logger) diskRoutine() {
+    interval := l.config.DiskCheckInterval
+    if interval <= 0 {
+        interval = defaultDiskCheckInterval
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ticker.C:
+            l.checkDisk()
+        case <-l.diskCheck:
+            l.checkDisk()
+        case <-l.stopChan:
+            return
+        }
+    }
+}
+
+// requestDiskCheck asks the disk guard for an immediate check, e.g. after a failed write
+func (l *Logger) requestDiskCheck() {
+    if l.diskCheck == nil {
+        return
+    }
+    select {
+    case l.diskCheck <- struct{}{}:
+    default:
+    }
+}
+
+// highWatermark returns the free space required to leave degraded mode
+func (l *Logger) highWatermark() uint64 {
+    if l.config.DiskHighWatermark > l.config.DiskLowWatermark {
+        return uint64(l.config.DiskHighWatermark)
+    }
+    return uint64(l.config.DiskLowWatermark)
+}
+
+// checkDisk compares free space against the watermarks, pruning backups and
+// switching between normal and degraded mode as needed
+func (l *Logger) checkDisk() {
+    free, err := diskFree(l.config.LogDir)
+    if err != nil {
+        fmt.Fprintf(diagnostics, "failed to check free disk space: %v\n", err)
+        return
+    }
+
+    l.mu.Lock()
+    var event *DiskEvent
+    low, high := uint64(l.config.DiskLowWatermark), l.highWatermark()
+    switch {
+    case !l.isDegraded() && free < low:
+        pruned := 0
+        free, pruned = l.pruneForSpace(free, high)
+        if free < low {
+            l.setDegraded(true)
+            event = &DiskEvent{Degraded: true, Free: free, Pruned: pruned}
+        }
+    case l.isDegraded() && free >= high:
+        l.setDegraded(false)
+        event = &DiskEvent{Degraded: false, Free: free}
+    }
+    l.mu.Unlock()
+
+    if event != nil {
+        if event.Degraded {
+            fmt.Fprintf(diagnostics, "Warning: low disk space (%d bytes free), log output degraded\n", event.Free)
+        }
+        if l.config.OnDiskState != nil {
+            l.config.OnDiskState(*event)
+        }
+    }
+}
+
+// setDegraded records a state change; the caller must hold l.mu
+func (l *Logger) setDegraded(degraded bool) {
+    var v int32
+    if degraded {
+        v = 1
+    }
+    atomic.StoreInt32(&l.degraded, v)
+    atomic.AddUint64(&l.diskStateChanges, 1)
+}
+
+// isDegraded reports whether the logger is in low disk space mode
+func (l *Logger) isDegraded() bool {
+    return atomic.LoadInt32(&l.degraded) == 1
+}
+
+// pruneForSpace deletes backups oldest first until free space reaches target,
+// returning the new free space and the number of deleted backups; the caller must hold l.mu
+func (l *Logger) pruneForSpace(free, target uint64) (uint64, int) {
+    files, err := l.getLogFiles()
+    if err != nil {
+        return free, 0
+    }
+
+    pruned := 0
+    for _, file := range files {
+        if free >= target {
+            break
+        }
+        if file.active {
+            continue
+        }
+        if err := removeBackup(file.path); err != nil {
+            fmt.Fprintf(diagnostics, "failed to delete log file %s to recover disk space: %v\n", file.path, err)
+            continue
+        }
+        pruned++
+        if f, err := diskFree(l.config.LogDir); err == nil {
+            free = f
+        }
+    }
+    return free, pruned
+}
+
+// degradedLevel returns the minimum level written to the file while degraded
+func (l *Logger) degradedLevel() LogLevel {
+    if l.config.DegradedLevel == DEBUG {
+        return ERROR
+    }
+    return l.config.DegradedLevel
+}
+
+// filterDegraded diverts or drops file-bound entries while disk space is low and
+// returns the entries that should still be written to the file
+func (l *Logger) filterDegraded(entries []*Entry) []*Entry {
+    if !l.isDegraded() {
+        return entries
+    }
+
+    if fallback := l.config.FallbackSink; fallback != nil {
+        for _, e := range entries {
+            if !fallback.Enabled(e.Level) {
+                continue
+            }
+            if err := fallback.Write(e); err != nil {
+                reportSinkError("fallback", err)
+            }
+        }
+        return nil
+    }
+
+    kept := make([]*Entry, 0, len(entries))
+    for _, e := range entries {
+        if e.Level >= l.degradedLevel() {
+            kept = append(kept, e)
+        } else {
+            atomic.AddUint64(&l.diskDropped, 1)
+        }
+    }
+    return kept
+}
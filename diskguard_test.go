@@ -0,0 +1,165 @@
+package logr
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+func TestDiskGuard(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_disk_guard"
+    defer os.RemoveAll(tempDir)
+
+    var free uint64 = 1 << 30
+    diskFree = func(path string) (uint64, error) {
+        return atomic.LoadUint64(&free), nil
+    }
+    defer func() { diskFree = freeDiskSpace }()
+
+    var mu sync.Mutex
+    var events []DiskEvent
+    config := &Config{
+        LogDir:            tempDir,
+        FileName:          "disk_test",
+        MaxSize:           100,
+        MaxAge:            time.Hour,
+        MaxBackups:        100,
+        Level:             DEBUG,
+        DiskLowWatermark:  1 << 20,
+        DiskHighWatermark: 2 << 20,
+        DiskCheckInterval: 5 * time.Millisecond,
+        OnDiskState: func(event DiskEvent) {
+            mu.Lock()
+            defer mu.Unlock()
+            events = append(events, event)
+        },
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    // Produce a few backups
+    for i := 0; i < 5; i++ {
+        logger.Info("record %d before the disk fills up, padded to rotate", i)
+    }
+
+    waitFor := func(cond func() bool) {
+        deadline := time.Now().Add(2 * time.Second)
+        for !cond() && time.Now().Before(deadline) {
+            time.Sleep(time.Millisecond)
+        }
+    }
+
+    // Below the low watermark: backups are pruned, then output degrades
+    atomic.StoreUint64(&free, 512<<10)
+    waitFor(func() bool { return logger.Stats().DiskDegraded })
+    if !logger.Stats().DiskDegraded {
+        t.Fatal("expected the logger to degrade below the low watermark")
+    }
+
+    files, err := logger.getLogFiles()
+    if err != nil {
+        t.Fatalf("failed to list log files: %v", err)
+    }
+    if len(files) != 1 || !files[0].active {
+        t.Errorf("expected every backup to be pruned, got %d files", len(files))
+    }
+
+    logger.Info("info while degraded")
+    logger.Error("error while degraded")
+
+    // Between the watermarks the logger stays degraded
+    atomic.StoreUint64(&free, 3<<19)
+    time.Sleep(20 * time.Millisecond)
+    if !logger.Stats().DiskDegraded {
+        t.Error("expected the logger to stay degraded below the high watermark")
+    }
+
+    // Above the high watermark normal logging resumes
+    atomic.StoreUint64(&free, 4<<20)
+    waitFor(func() bool { return !logger.Stats().DiskDegraded })
+    logger.Info("info after recovery")
+
+    files, err = logger.getLogFiles()
+    if err != nil {
+        t.Fatalf("failed to list log files: %v", err)
+    }
+    var content []byte
+    for _, file := range files {
+        data, err := os.ReadFile(file.path)
+        if err != nil {
+            t.Fatalf("failed to read log file: %v", err)
+        }
+        content = append(content, data...)
+    }
+    if strings.Contains(string(content), "info while degraded") {
+        t.Error("INFO records should be dropped while degraded")
+    }
+    if !strings.Contains(string(content), "error while degraded") {
+        t.Error("ERROR records should be kept while degraded")
+    }
+    if !strings.Contains(string(content), "info after recovery") {
+        t.Error("INFO records should be written after recovery")
+    }
+
+    stats := logger.Stats()
+    if stats.DiskDropped != 1 || stats.DiskStateChanges != 2 {
+        t.Errorf("unexpected stats: %+v", stats)
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    if len(events) != 2 || !events[0].Degraded || events[0].Pruned == 0 || events[1].Degraded {
+        t.Errorf("unexpected disk events: %+v", events)
+    }
+}
+
+func TestDiskGuardFallbackSink(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_disk_fallback"
+    defer os.RemoveAll(tempDir)
+
+    diskFree = func(path string) (uint64, error) {
+        return 0, nil
+    }
+    defer func() { diskFree = freeDiskSpace }()
+
+    fallback := &recordingSink{}
+    config := &Config{
+        LogDir:           tempDir,
+        FileName:         "fallback_test",
+        MaxSize:          1024 * 1024,
+        Level:            DEBUG,
+        DiskLowWatermark: 1 << 20,
+        FallbackSink:     fallback,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    // The first check runs on startup, so output is degraded right away
+    logger.Debug("to fallback")
+    logger.Error("also to fallback")
+    logger.Close()
+
+    if len(fallback.entries) != 2 {
+        t.Errorf("expected both records in the fallback sink, got %d", len(fallback.entries))
+    }
+    if !fallback.closed {
+        t.Error("expected close to propagate to the fallback sink")
+    }
+    content, _ := os.ReadFile(filepath.Join(tempDir, "fallback_test.log"))
+    if len(content) != 0 {
+        t.Errorf("expected nothing in the log file, got %q", content)
+    }
+}
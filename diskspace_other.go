@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package logr
+
+import (
+    "errors"
+)
+
+// freeDiskSpace is not supported on this platform, which disables the disk guard
+func freeDiskSpace(path string) (uint64, error) {
+    return 0, errors.New("free disk space is not supported on this platform")
+}
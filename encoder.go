@@ -0,0 +1,262 @@
+package logr
+
+import (
+    "encoding/json"
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+const (
+    textTimeLayout = "2006-01-02 15:04:05.000"
+    jsonTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// Entry represents a single log record
+type Entry struct {
+    Time    time.Time
+    Level   LogLevel
+    Message string
+    Fields  []Field
+}
+
+// Encoder serializes log entries into lines of output
+type Encoder interface {
+    // Encode returns the encoded entry, including the trailing newline
+    Encode(e *Entry) ([]byte, error)
+}
+
+// MultilineMode selects how the text encoder writes newlines and other
+// control characters in messages
+type MultilineMode int
+
+const (
+    // MultilineEscape writes newlines as \n and other control characters as
+    // \r or \xNN, and doubles backslashes, keeping every record on one line
+    MultilineEscape MultilineMode = iota
+    // MultilineIndent writes each further line of a message on a line of its
+    // own after ContinuationMarker, which the reader joins back into the
+    // record; other control characters and backslashes are escaped
+    MultilineIndent
+    // MultilineRaw writes messages verbatim. User input in messages can then
+    // forge records.
+    MultilineRaw
+)
+
+// ContinuationMarker starts the continuation lines of a multiline message
+// written with MultilineIndent
+const ContinuationMarker = "  | "
+
+// TextEncoder encodes entries as "[timestamp] [LEVEL] message key=value"
+type TextEncoder struct {
+    Multiline MultilineMode // How messages with newlines and control characters are written
+}
+
+// NewTextEncoder creates a text encoder that escapes newlines and control
+// characters in messages
+func NewTextEncoder() *TextEncoder {
+    return &TextEncoder{}
+}
+
+// Encode implements Encoder
+func (enc *TextEncoder) Encode(e *Entry) ([]byte, error) {
+    var sb strings.Builder
+    sb.WriteString("[" + e.Time.Format(textTimeLayout) + "] [" + e.Level.String() + "] ")
+    writeTextMessage(&sb, e.Message, enc.Multiline)
+    appendFields(&sb, e.Fields)
+    sb.WriteByte('\n')
+    return []byte(sb.String()), nil
+}
+
+// writeTextMessage writes a message so that it cannot end its record early
+// or start a forged one
+func writeTextMessage(sb *strings.Builder, msg string, mode MultilineMode) {
+    if mode == MultilineRaw || strings.IndexFunc(msg, needsTextEscape) < 0 {
+        sb.WriteString(msg)
+        return
+    }
+    for _, r := range msg {
+        switch {
+        case r == '\\':
+            sb.WriteString(`\\`)
+        case r == '\n' && mode == MultilineIndent:
+            sb.WriteString("\n" + ContinuationMarker)
+        case r == '\n':
+            sb.WriteString(`\n`)
+        case r == '\r':
+            sb.WriteString(`\r`)
+        case r == '\t':
+            sb.WriteRune(r)
+        case r < 0x20 || r == 0x7f:
+            fmt.Fprintf(sb, `\x%02x`, r)
+        case r >= 0x80 && r < 0xa0 || r == '\u2028' || r == '\u2029':
+            // C1 controls and Unicode line separators end lines for some tools
+            fmt.Fprintf(sb, `\u%04x`, r)
+        default:
+            sb.WriteRune(r)
+        }
+    }
+}
+
+// needsTextEscape reports whether a message character is escaped by writeTextMessage
+func needsTextEscape(r rune) bool {
+    return r == '\\' || (r < 0x20 && r != '\t') || r == 0x7f || (r >= 0x80 && r < 0xa0) || r == '\u2028' || r == '\u2029'
+}
+
+// JSONEncoder encodes entries as JSON lines with ts, level and msg keys
+type JSONEncoder struct{}
+
+// NewJSONEncoder creates a JSON-lines encoder
+func NewJSONEncoder() *JSONEncoder {
+    return &JSONEncoder{}
+}
+
+// Encode implements Encoder
+func (enc *JSONEncoder) Encode(e *Entry) ([]byte, error) {
+    buf := make([]byte, 0, 128+len(e.Message))
+    buf = append(buf, '{')
+    // An entry without a time, such as a slog record with a zero time, has no "ts"
+    if !e.Time.IsZero() {
+        buf = append(buf, `"ts":`...)
+        buf = appendJSONString(buf, e.Time.Format(jsonTimeLayout))
+        buf = append(buf, ',')
+    }
+    buf = append(buf, `"level":`...)
+    buf = appendJSONString(buf, e.Level.lowerString())
+    buf = append(buf, `,"msg":`...)
+    buf = appendJSONString(buf, e.Message)
+    buf = appendJSONFields(buf, e.Fields, false, true)
+    buf = append(buf, '}', '\n')
+    return buf, nil
+}
+
+// LogfmtEncoder encodes entries as logfmt lines: ts=... level=info msg="..." key=value
+type LogfmtEncoder struct{}
+
+// NewLogfmtEncoder creates a logfmt encoder
+func NewLogfmtEncoder() *LogfmtEncoder {
+    return &LogfmtEncoder{}
+}
+
+// Encode implements Encoder
+func (enc *LogfmtEncoder) Encode(e *Entry) ([]byte, error) {
+    var sb strings.Builder
+    sb.WriteString("ts=" + e.Time.Format(jsonTimeLayout))
+    sb.WriteString(" level=" + e.Level.lowerString())
+    sb.WriteString(" msg=")
+    writeLogfmtValue(&sb, e.Message)
+    appendFields(&sb, e.Fields)
+    sb.WriteByte('\n')
+    return []byte(sb.String()), nil
+}
+
+// writeLogfmtKey writes a key, replacing characters logfmt does not allow in keys
+func writeLogfmtKey(sb *strings.Builder, key string) {
+    if key == "" {
+        sb.WriteByte('_')
+        return
+    }
+    for _, r := range key {
+        if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || needsTextEscape(r) {
+            sb.WriteByte('_')
+        } else {
+            sb.WriteRune(r)
+        }
+    }
+}
+
+// writeLogfmtValue writes a value, quoting it if it contains spaces, quotes, '=' or control characters
+func writeLogfmtValue(sb *strings.Builder, value string) {
+    if needsQuoting(value) {
+        sb.WriteString(strconv.Quote(value))
+    } else {
+        sb.WriteString(value)
+    }
+}
+
+// appendJSONValue appends a field value as a JSON value
+func appendJSONValue(buf []byte, f Field) []byte {
+    switch f.Type {
+    case GroupType:
+        buf = append(buf, '{')
+        buf = appendJSONFields(buf, f.Interface.([]Field), true, false)
+        return append(buf, '}')
+    case Int64Type:
+        return strconv.AppendInt(buf, f.Integer, 10)
+    case AnyType:
+        data, err := json.Marshal(f.Interface)
+        if err != nil {
+            return appendJSONString(buf, fmt.Sprint(f.Interface))
+        }
+        return append(buf, data...)
+    default:
+        return appendJSONString(buf, f.ValueString())
+    }
+}
+
+// appendJSONFields appends fields as JSON object members. Each member is
+// preceded by a comma, except the first one written when first is set. The
+// members of groups with an empty key are written inline, and the keys of
+// top-level members are renamed by fieldKey.
+func appendJSONFields(buf []byte, fields []Field, first, top bool) []byte {
+    for _, f := range fields {
+        if f.Type == GroupType && f.Key == "" {
+            n := len(buf)
+            buf = appendJSONFields(buf, f.Interface.([]Field), first, top)
+            first = first && len(buf) == n
+            continue
+        }
+        if !first {
+            buf = append(buf, ',')
+        }
+        first = false
+        key := f.Key
+        if top {
+            key = fieldKey(key)
+        }
+        buf = appendJSONString(buf, key)
+        buf = append(buf, ':')
+        buf = appendJSONValue(buf, f)
+    }
+    return buf
+}
+
+// appendJSONString appends s as a quoted and escaped JSON string
+func appendJSONString(buf []byte, s string) []byte {
+    const hex = "0123456789abcdef"
+    buf = append(buf, '"')
+    for i := 0; i < len(s); {
+        c := s[i]
+        if c < utf8.RuneSelf {
+            switch {
+            case c == '"' || c == '\\':
+                buf = append(buf, '\\', c)
+            case c == '\n':
+                buf = append(buf, '\\', 'n')
+            case c == '\r':
+                buf = append(buf, '\\', 'r')
+            case c == '\t':
+                buf = append(buf, '\\', 't')
+            case c < 0x20:
+                buf = append(buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+            default:
+                buf = append(buf, c)
+            }
+            i++
+            continue
+        }
+        r, size := utf8.DecodeRuneInString(s[i:])
+        switch {
+        case r == utf8.RuneError && size == 1:
+            buf = append(buf, `\ufffd`...)
+        case r == '\u2028' || r == '\u2029':
+            buf = append(buf, '\\', 'u', '2', '0', '2', hex[r&0xf])
+        default:
+            buf = append(buf, s[i:i+size]...)
+        }
+        i += size
+    }
+    return append(buf, '"')
+}
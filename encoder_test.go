@@ -0,0 +1,255 @@
+package logr
+
+import (
+    "encoding/json"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestJSONEncoder(t *testing.T) {
+    entry := &Entry{
+        Time:    time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+        Level:   WARN,
+        Message: "quote \" backslash \\ newline \n tab \t ctrl \x01",
+        Fields: []Field{
+            String("user", "alice"),
+            Int64("rows", 12),
+            Duration("latency", 250*time.Millisecond),
+            Any("tags", []string{"a", "b"}),
+        },
+    }
+
+    line, err := NewJSONEncoder().Encode(entry)
+    if err != nil {
+        t.Fatalf("failed to encode entry: %v", err)
+    }
+    if strings.Count(string(line), "\n") != 1 || !strings.HasSuffix(string(line), "\n") {
+        t.Fatalf("expected a single line, got %q", line)
+    }
+
+    var decoded map[string]interface{}
+    if err := json.Unmarshal(line, &decoded); err != nil {
+        t.Fatalf("encoded line is not valid JSON: %v: %s", err, line)
+    }
+    if decoded["ts"] != "2024-01-02T03:04:05.006Z" {
+        t.Errorf("unexpected ts: %v", decoded["ts"])
+    }
+    if decoded["level"] != "warn" {
+        t.Errorf("unexpected level: %v", decoded["level"])
+    }
+    if decoded["msg"] != entry.Message {
+        t.Errorf("message did not round trip: %q", decoded["msg"])
+    }
+    if decoded["rows"] != float64(12) || decoded["latency"] != "250ms" || decoded["user"] != "alice" {
+        t.Errorf("unexpected fields: %v", decoded)
+    }
+    if tags, ok := decoded["tags"].([]interface{}); !ok || len(tags) != 2 {
+        t.Errorf("unexpected tags field: %v", decoded["tags"])
+    }
+}
+
+func TestJSONEncoderRotationSize(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_json"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:       tempDir,
+        FileName:     "json_test",
+        MaxSize:      200,
+        MaxAge:       time.Hour,
+        MaxBackups:   10,
+        Level:        INFO,
+        EnableStdout: false,
+        Encoder:      NewJSONEncoder(),
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    for i := 0; i < 5; i++ {
+        logger.Infow("json record for rotation", Int("n", i))
+    }
+
+    content, err := os.ReadFile(filepath.Join(tempDir, "json_test.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    if int64(len(content)) > config.MaxSize {
+        t.Errorf("active file exceeds MaxSize: %d bytes", len(content))
+    }
+    for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+        var decoded map[string]interface{}
+        if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+            t.Errorf("invalid JSON line %q: %v", line, err)
+        }
+    }
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+    entry := &Entry{
+        Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+        Level:   INFO,
+        Message: "slow query",
+        Fields: []Field{
+            String("sql", `SELECT * FROM t WHERE a = "x"`),
+            String("path", "/var/lib/db"),
+            String("multi", "line1\nline2"),
+            String("empty", ""),
+            String("bad key", "v"),
+            Int64("rows", 3),
+        },
+    }
+
+    line, err := NewLogfmtEncoder().Encode(entry)
+    if err != nil {
+        t.Fatalf("failed to encode entry: %v", err)
+    }
+
+    expected := `ts=2024-01-02T03:04:05.000Z level=info msg="slow query" ` +
+        `sql="SELECT * FROM t WHERE a = \"x\"" path=/var/lib/db multi="line1\nline2" empty="" bad_key=v rows=3` + "\n"
+    if string(line) != expected {
+        t.Errorf("unexpected logfmt output:\n got: %s want: %s", line, expected)
+    }
+}
+
+func TestTextEncoderEscaping(t *testing.T) {
+    forged := "login failed\n[2025-01-01 00:00:00.000] [ERROR] fake\r\x1b[2J C:\\tmp\ttab \u2028end"
+    entry := &Entry{
+        Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local),
+        Level:   INFO,
+        Message: forged,
+    }
+
+    line, err := NewTextEncoder().Encode(entry)
+    if err != nil {
+        t.Fatalf("failed to encode entry: %v", err)
+    }
+    expected := `[2024-01-02 03:04:05.000] [INFO] login failed\n[2025-01-01 00:00:00.000] [ERROR] fake\r\x1b[2J C:\\tmp` + "\ttab " + `\u2028end` + "\n"
+    if string(line) != expected {
+        t.Fatalf("unexpected escaped output:\n got: %q\nwant: %q", line, expected)
+    }
+
+    indented, err := (&TextEncoder{Multiline: MultilineIndent}).Encode(entry)
+    if err != nil {
+        t.Fatalf("failed to encode entry: %v", err)
+    }
+    lines := strings.Split(strings.TrimSuffix(string(indented), "\n"), "\n")
+    if len(lines) != 2 || !strings.HasPrefix(lines[1], ContinuationMarker+"[2025-01-01") {
+        t.Fatalf("expected an indented continuation line, got %q", indented)
+    }
+
+    // Both forms parse back to the original message
+    for _, raw := range []string{string(line), string(indented)} {
+        parsed, err := ParseLine(strings.TrimSuffix(raw, "\n"))
+        if err != nil {
+            t.Fatalf("failed to parse %q: %v", raw, err)
+        }
+        if parsed.Level != INFO || parsed.Message != forged {
+            t.Errorf("message did not round trip: %q", parsed.Message)
+        }
+    }
+
+    raw, _ := (&TextEncoder{Multiline: MultilineRaw}).Encode(entry)
+    if strings.Count(string(raw), "\n") != 2 {
+        t.Errorf("expected raw mode to write the message verbatim, got %q", raw)
+    }
+
+    // Field values are quoted and escaped for the same characters
+    entry = &Entry{
+        Time:    entry.Time,
+        Level:   INFO,
+        Message: "fields",
+        Fields:  []Field{String("nel", "x\u0085y"), String("ls", "a\u2028b"), String("csi", "\u009b2J")},
+    }
+    line, err = NewTextEncoder().Encode(entry)
+    if err != nil {
+        t.Fatalf("failed to encode entry: %v", err)
+    }
+    expected = `[2024-01-02 03:04:05.000] [INFO] fields nel="x\u0085y" ls="a\u2028b" csi="\u009b2J"` + "\n"
+    if string(line) != expected {
+        t.Errorf("unexpected escaped field values:\n got: %q\nwant: %q", line, expected)
+    }
+}
+
+func TestTextEncoderKeySanitizing(t *testing.T) {
+    entry := &Entry{
+        Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.Local),
+        Level:   INFO,
+        Message: "login",
+        Fields: []Field{
+            String("user\n[2025-01-01 00:00:00.000] [ERROR] fake", "v"),
+            String("a=b", "c"),
+            String("", "empty"),
+            Group("req\r", String("id", "1")),
+            String("nel\u0085ls\u2028", "2"),
+        },
+    }
+
+    line, err := NewTextEncoder().Encode(entry)
+    if err != nil {
+        t.Fatalf("failed to encode entry: %v", err)
+    }
+    expected := "[2024-01-02 03:04:05.000] [INFO] login user_[2025-01-01_00:00:00.000]_[ERROR]_fake=v a_b=c _=empty req_.id=1 nel_ls_=2\n"
+    if string(line) != expected {
+        t.Fatalf("unexpected sanitized output:\n got: %q\nwant: %q", line, expected)
+    }
+    if got := Group("g", String("x y", "1")).ValueString(); got != "{x_y=1}" {
+        t.Errorf("unexpected group value %q", got)
+    }
+}
+
+func TestReservedFieldKeys(t *testing.T) {
+    entry := &Entry{
+        Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+        Level:   INFO,
+        Message: "m",
+        Fields: []Field{
+            String("level", "fatal"),
+            String("msg", "forged"),
+            Group("", String("ts", "2000-01-01T00:00:00.000Z")),
+            Group("req", String("level", "nested")),
+        },
+    }
+
+    for name, encoder := range map[string]Encoder{"json": NewJSONEncoder(), "logfmt": NewLogfmtEncoder()} {
+        line, err := encoder.Encode(entry)
+        if err != nil {
+            t.Fatalf("%s: failed to encode entry: %v", name, err)
+        }
+        parsed, err := ParseLine(strings.TrimSuffix(string(line), "\n"))
+        if err != nil {
+            t.Fatalf("%s: failed to parse %q: %v", name, line, err)
+        }
+        if parsed.Level != INFO || parsed.Message != "m" || !parsed.Time.Equal(entry.Time) {
+            t.Errorf("%s: fields replaced the record: %+v from %q", name, parsed, line)
+        }
+        fields := map[string]string{}
+        for _, f := range parsed.Fields {
+            fields[f.Key] = f.ValueString()
+        }
+        if fields["fields.level"] != "fatal" || fields["fields.msg"] != "forged" || fields["fields.ts"] != "2000-01-01T00:00:00.000Z" {
+            t.Errorf("%s: expected prefixed reserved keys, got %v from %q", name, fields, line)
+        }
+    }
+
+    // Lines with duplicate keys keep the first ts, level and msg
+    for _, line := range []string{
+        `{"ts":"2024-01-02T03:04:05.000Z","level":"info","msg":"m","level":"fatal","msg":"forged"}`,
+        `ts=2024-01-02T03:04:05.000Z level=info msg=m level=fatal msg=forged`,
+    } {
+        parsed, err := ParseLine(line)
+        if err != nil {
+            t.Fatalf("failed to parse %q: %v", line, err)
+        }
+        if parsed.Level != INFO || parsed.Message != "m" || len(parsed.Fields) != 2 {
+            t.Errorf("expected the first level and msg of %q, got %+v", line, parsed)
+        }
+    }
+}
@@ -0,0 +1,547 @@
+package logr
+
+import (
+    "bufio"
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "sync/atomic"
+)
+
+const (
+    encryptionMagic    = "LOGRENC1" // First bytes of every encrypted log file
+    encryptSegmentSize = 64 * 1024  // Maximum plaintext bytes per sealed segment
+    encryptSaltSize    = 8          // Random per-file nonce prefix
+    encryptTempExt     = ".enc.tmp" // Suffix of files being encrypted
+)
+
+// Encryption failures
+var (
+    ErrEncrypted = errors.New("log file is encrypted and no key provider was given")
+    ErrDecrypt   = errors.New("encrypted log segment failed authentication")
+    ErrTruncated = errors.New("encrypted log file ends before its final segment")
+)
+
+var (
+    errEncryptActiveWithoutKeys = errors.New("EncryptActive requires EncryptionKeys")
+    errSegmentsFinished         = errors.New("encrypted log file is finished")
+)
+
+// KeyProvider supplies the AES keys that encrypt log files at rest. New files
+// are encrypted with the current key; its ID is stored in each file's header,
+// so files written before the current key changed can still be decrypted.
+type KeyProvider interface {
+    CurrentKey() (id string, key []byte, err error) // Key for new files; 16, 24 or 32 bytes
+    Key(id string) ([]byte, error)                  // Key with the given ID, for decryption
+}
+
+// CurrentKey implements KeyProvider with the active key
+func (k *Keyring) CurrentKey() (string, []byte, error) {
+    key, err := k.Key(k.ActiveKeyID)
+    return k.ActiveKeyID, key, err
+}
+
+// Key implements KeyProvider
+func (k *Keyring) Key(id string) ([]byte, error) {
+    key, ok := k.Keys[id]
+    if !ok {
+        return nil, fmt.Errorf("no key with ID %q in the keyring", id)
+    }
+    return key, nil
+}
+
+// keyError reports that the key of an encrypted file is not available
+type keyError struct {
+    id  string
+    err error
+}
+
+func (e *keyError) Error() string {
+    return fmt.Sprintf("encryption key %q unavailable: %v", e.id, e.err)
+}
+
+func (e *keyError) Unwrap() error {
+    return e.err
+}
+
+// segmentCipher seals or opens the segments of one encrypted file.
+//
+// An encrypted file is a header followed by segments. The header holds the
+// magic, the key ID and a random salt. Each segment is a 4-byte big-endian
+// length and an AES-GCM ciphertext of up to encryptSegmentSize bytes, sealed
+// with the salt and the segment index as nonce and the header and index as
+// additional data, so segments cannot be reordered, dropped in the middle or
+// moved to another file. The last segment of a finished file, such as a
+// backup, is also marked final in its additional data, so that a file cut at
+// a segment boundary is not mistaken for a complete one; the active file has
+// no final segment until it is rotated.
+type segmentCipher struct {
+    aead   cipher.AEAD
+    header []byte // Encoded file header
+    salt   []byte
+    count  uint32 // Index of the next segment
+}
+
+// newSegmentCipher creates the cipher for a new file with the current key
+func newSegmentCipher(keys KeyProvider) (*segmentCipher, error) {
+    id, key, err := keys.CurrentKey()
+    if err != nil {
+        return nil, fmt.Errorf("failed to get encryption key: %v", err)
+    }
+    if len(id) > 255 {
+        return nil, errors.New("encryption key ID is longer than 255 bytes")
+    }
+    salt := make([]byte, encryptSaltSize)
+    if _, err := rand.Read(salt); err != nil {
+        return nil, fmt.Errorf("failed to generate salt: %v", err)
+    }
+
+    header := append([]byte(encryptionMagic), byte(len(id)))
+    header = append(header, id...)
+    header = append(header, salt...)
+    return makeSegmentCipher(key, header, salt)
+}
+
+func makeSegmentCipher(key, header, salt []byte) (*segmentCipher, error) {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, fmt.Errorf("invalid encryption key: %v", err)
+    }
+    aead, err := cipher.NewGCM(block)
+    if err != nil {
+        return nil, err
+    }
+    return &segmentCipher{aead: aead, header: header, salt: salt}, nil
+}
+
+// readEncryptionHeader reads the header of an encrypted file and returns the
+// cipher for its segments
+func readEncryptionHeader(r io.Reader, keys KeyProvider) (*segmentCipher, error) {
+    header := make([]byte, len(encryptionMagic)+1)
+    if _, err := io.ReadFull(r, header); err != nil {
+        return nil, fmt.Errorf("invalid encryption header: %v", err)
+    }
+    if string(header[:len(encryptionMagic)]) != encryptionMagic {
+        return nil, errors.New("not an encrypted log file")
+    }
+    rest := make([]byte, int(header[len(encryptionMagic)])+encryptSaltSize)
+    if _, err := io.ReadFull(r, rest); err != nil {
+        return nil, fmt.Errorf("invalid encryption header: %v", err)
+    }
+    header = append(header, rest...)
+
+    if keys == nil {
+        return nil, ErrEncrypted
+    }
+    id := string(rest[:len(rest)-encryptSaltSize])
+    key, err := keys.Key(id)
+    if err != nil {
+        return nil, &keyError{id: id, err: err}
+    }
+    return makeSegmentCipher(key, header, rest[len(rest)-encryptSaltSize:])
+}
+
+// nonce returns the nonce of segment n
+func (c *segmentCipher) nonce(n uint32) []byte {
+    nonce := make([]byte, 0, c.aead.NonceSize())
+    nonce = append(nonce, c.salt...)
+    return appendUint32(nonce, n)
+}
+
+// additionalData returns the authenticated data of segment n
+func (c *segmentCipher) additionalData(n uint32, final bool) []byte {
+    ad := append([]byte{}, c.header...)
+    ad = appendUint32(ad, n)
+    if final {
+        ad = append(ad, 1)
+    }
+    return ad
+}
+
+// seal appends the next segment, with its length prefix, to dst; final marks
+// the last segment of a finished file
+func (c *segmentCipher) seal(dst, plaintext []byte, final bool) []byte {
+    n := c.count
+    c.count++
+    start := len(dst)
+    dst = append(dst, 0, 0, 0, 0)
+    dst = c.aead.Seal(dst, c.nonce(n), plaintext, c.additionalData(n, final))
+    binary.BigEndian.PutUint32(dst[start:], uint32(len(dst)-start-4))
+    return dst
+}
+
+// open decrypts the next segment and reports whether it is the final one
+func (c *segmentCipher) open(segment []byte) ([]byte, bool, error) {
+    nonce := c.nonce(c.count)
+    final := false
+    plaintext, err := c.aead.Open(nil, nonce, segment, c.additionalData(c.count, false))
+    if err != nil {
+        final = true
+        plaintext, err = c.aead.Open(nil, nonce, segment, c.additionalData(c.count, true))
+    }
+    if err != nil {
+        return nil, false, ErrDecrypt
+    }
+    c.count++
+    return plaintext, final, nil
+}
+
+// maxSegment returns the size limit of a segment's ciphertext
+func (c *segmentCipher) maxSegment() int {
+    return encryptSegmentSize + c.aead.Overhead()
+}
+
+// readSegment reads the ciphertext of the next segment. A segment cut short
+// by a crash reads as io.EOF, like the end of the file.
+func readSegment(r io.Reader, max int) ([]byte, error) {
+    var prefix [4]byte
+    if _, err := io.ReadFull(r, prefix[:]); err != nil {
+        if err == io.ErrUnexpectedEOF {
+            return nil, io.EOF
+        }
+        return nil, err
+    }
+    n := binary.BigEndian.Uint32(prefix[:])
+    if n > uint32(max) {
+        return nil, fmt.Errorf("encrypted segment of %d bytes exceeds the maximum of %d", n, max)
+    }
+    segment := make([]byte, n)
+    if _, err := io.ReadFull(r, segment); err != nil {
+        if err == io.ErrUnexpectedEOF || err == io.EOF {
+            return nil, io.EOF
+        }
+        return nil, err
+    }
+    return segment, nil
+}
+
+// decryptReader streams the plaintext of an encrypted file
+type decryptReader struct {
+    r        io.Reader
+    c        *segmentCipher
+    finished bool   // Whether the file must end with its final segment
+    final    bool   // Whether the final segment was read
+    buf      []byte // Decrypted bytes not read yet
+    err      error
+}
+
+// newDecryptReader reads the encryption header from r and returns a reader
+// of the plaintext that follows. A finished file, unlike the active file,
+// must end with its final segment and reads as ErrTruncated otherwise.
+func newDecryptReader(r io.Reader, keys KeyProvider, finished bool) (io.Reader, error) {
+    c, err := readEncryptionHeader(r, keys)
+    if err != nil {
+        return nil, err
+    }
+    return &decryptReader{r: r, c: c, finished: finished}, nil
+}
+
+// Read implements io.Reader
+func (d *decryptReader) Read(p []byte) (int, error) {
+    for len(d.buf) == 0 {
+        if d.err != nil {
+            return 0, d.err
+        }
+        segment, err := readSegment(d.r, d.c.maxSegment())
+        if err == io.EOF && d.finished && !d.final {
+            err = ErrTruncated
+        }
+        if err != nil {
+            d.err = err
+            continue
+        }
+        if d.final {
+            // Nothing may follow the final segment
+            d.err = ErrDecrypt
+            continue
+        }
+        if d.buf, d.final, err = d.c.open(segment); err != nil {
+            d.err = err
+        }
+    }
+    n := copy(p, d.buf)
+    d.buf = d.buf[n:]
+    return n, nil
+}
+
+// isEncrypted reports whether a file starts with the encryption header
+func isEncrypted(f io.ReaderAt) bool {
+    magic := make([]byte, len(encryptionMagic))
+    _, err := f.ReadAt(magic, 0)
+    return err == nil && string(magic) == encryptionMagic
+}
+
+// isEncryptedPath reports whether the file at path is encrypted
+func isEncryptedPath(path string) bool {
+    f, err := os.Open(path)
+    if err != nil {
+        return false
+    }
+    defer f.Close()
+    return isEncrypted(f)
+}
+
+// openPlaintext returns a reader of a finished log file's content,
+// decrypting it if needed
+func openPlaintext(f *os.File, keys KeyProvider) (io.Reader, error) {
+    if !isEncrypted(f) {
+        return f, nil
+    }
+    return newDecryptReader(f, keys, true)
+}
+
+// encryptFile encrypts srcPath to dstPath, which may be the same file. The
+// result is written to a temporary file and atomically renamed to dstPath,
+// so a partially encrypted file never has the final name.
+func encryptFile(srcPath, dstPath string, keys KeyProvider) error {
+    src, err := os.Open(srcPath)
+    if err != nil {
+        return fmt.Errorf("failed to open source file: %v", err)
+    }
+    defer src.Close()
+
+    c, err := newSegmentCipher(keys)
+    if err != nil {
+        return err
+    }
+    tmpPath := dstPath + encryptTempExt
+    dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+    if err != nil {
+        return fmt.Errorf("failed to create encrypted file: %v", err)
+    }
+
+    if err := writeEncrypted(dst, src, c); err != nil {
+        dst.Close()
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to encrypt file content: %v", err)
+    }
+    if err := dst.Sync(); err != nil {
+        dst.Close()
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to sync encrypted file: %v", err)
+    }
+    if err := dst.Close(); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to close encrypted file: %v", err)
+    }
+    if err := os.Rename(tmpPath, dstPath); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to rename encrypted file: %v", err)
+    }
+    return nil
+}
+
+// writeEncrypted writes the header and the content of r as sealed segments,
+// the last one marked final
+func writeEncrypted(w io.Writer, r io.Reader, c *segmentCipher) error {
+    if _, err := w.Write(c.header); err != nil {
+        return err
+    }
+    buf := make([]byte, encryptSegmentSize)
+    next := make([]byte, encryptSegmentSize)
+    n, err := io.ReadFull(r, buf)
+    var out []byte
+    for err == nil {
+        // Read ahead to know whether the full segment in buf is the last one
+        m, nextErr := io.ReadFull(r, next)
+        if m == 0 && nextErr == io.EOF {
+            break
+        }
+        out = c.seal(out[:0], buf[:n], false)
+        if _, err := w.Write(out); err != nil {
+            return err
+        }
+        buf, next = next, buf
+        n, err = m, nextErr
+    }
+    if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+        return err
+    }
+
+    // The content ends with this segment, which may be empty
+    out = c.seal(out[:0], buf[:n], true)
+    _, err = w.Write(out)
+    return err
+}
+
+// resumeSegments prepares appending to an encrypted active file. It returns
+// the cipher continuing after the last complete segment and the offset at
+// which that segment ends; a crash may have left a partial segment after it.
+// A file finished by a rotation that a crash interrupted cannot be resumed.
+func resumeSegments(path string, keys KeyProvider) (*segmentCipher, int64, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer f.Close()
+
+    r := bufio.NewReaderSize(f, 64*1024)
+    c, err := readEncryptionHeader(r, keys)
+    if err != nil {
+        return nil, 0, err
+    }
+    end := int64(len(c.header))
+    var last []byte
+    for {
+        segment, err := readSegment(r, c.maxSegment())
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, 0, err
+        }
+        c.count++
+        end += 4 + int64(len(segment))
+        last = segment
+    }
+
+    // Only the last segment can be final
+    if last != nil {
+        c.count--
+        _, final, err := c.open(last)
+        if err != nil {
+            return nil, 0, err
+        }
+        if final {
+            return nil, 0, errSegmentsFinished
+        }
+    }
+    return c, end, nil
+}
+
+// finishEncryptedFile appends the final segment to an encrypted active file
+// that a plain one replaces, dropping a partial segment left by a crash
+func finishEncryptedFile(path string, keys KeyProvider) error {
+    c, end, err := resumeSegments(path, keys)
+    if err == errSegmentsFinished {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    f, err := os.OpenFile(path, os.O_WRONLY, 0)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+    if err := f.Truncate(end); err != nil {
+        return err
+    }
+    if _, err := f.WriteAt(c.seal(nil, nil, true), end); err != nil {
+        return err
+    }
+    return f.Sync()
+}
+
+// openSegments prepares sealing records to the active file at path. A file
+// that is not encrypted or cannot be continued is left without a cipher and
+// rotated away on startup.
+func (l *Logger) openSegments(path string) error {
+    l.segments, l.segmentBuf, l.segmentRecords, l.segmentsBroken = nil, nil, 0, false
+    if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+        c, end, err := resumeSegments(path, l.config.EncryptionKeys)
+        if err != nil {
+            return nil
+        }
+        if c.count == 0 {
+            // No segment survived; the file starts over
+            end = 0
+        }
+        if end < info.Size() {
+            // Drop the partial segment of a write interrupted by a crash
+            if err := os.Truncate(path, end); err != nil {
+                return fmt.Errorf("failed to truncate partial segment: %v", err)
+            }
+        }
+        if c.count > 0 {
+            l.segments = c
+            return nil
+        }
+    }
+
+    c, err := newSegmentCipher(l.config.EncryptionKeys)
+    if err != nil {
+        return err
+    }
+    l.segments = c
+    return nil
+}
+
+// activeFormatChanged reports whether the active file was written with a
+// different EncryptActive setting, or cannot be continued, and must be rotated
+func (l *Logger) activeFormatChanged() bool {
+    if l.currentSize == 0 || l.file == nil {
+        return false
+    }
+    if l.config.EncryptActive {
+        return l.segments == nil
+    }
+    return isEncryptedPath(l.getCurrentLogPath())
+}
+
+// sealSegments encrypts the buffered records of the active file and appends
+// them as sealed segments; the caller must hold l.mu. The nonces of segments
+// that fail to be written are never used again: the partial ciphertext may
+// have reached the disk or a follower, so the file is marked broken and
+// rotated before the next write, and its lost records are counted as dropped.
+func (l *Logger) sealSegments() error {
+    if l.segments == nil || len(l.segmentBuf) == 0 || l.file == nil {
+        return nil
+    }
+
+    var out []byte
+    if l.segments.count == 0 {
+        // The header is written with the first segment, so an empty file stays empty
+        out = append(out, l.segments.header...)
+    }
+    for rest := l.segmentBuf; len(rest) > 0; {
+        n := len(rest)
+        if n > encryptSegmentSize {
+            n = encryptSegmentSize
+        }
+        out = l.segments.seal(out, rest[:n], false)
+        rest = rest[n:]
+    }
+    records := l.segmentRecords
+    l.segmentBuf, l.segmentRecords = l.segmentBuf[:0], 0
+
+    info, statErr := l.file.Stat()
+    if _, err := l.file.Write(out); err != nil {
+        // Cut a partial segment off so readers stop at the last whole one
+        if statErr == nil {
+            if terr := l.file.Truncate(info.Size()); terr != nil {
+                fmt.Fprintf(diagnostics, "Warning: failed to truncate partial segment: %v\n", terr)
+            }
+        }
+        l.segmentsBroken = true
+        atomic.AddUint64(&l.dropped, uint64(records))
+        l.requestDiskCheck()
+        return fmt.Errorf("failed to write to log file: %v", err)
+    }
+    return nil
+}
+
+// finishSegments appends the final segment to the encrypted active file
+// before it is rotated; the caller must hold l.mu and seal the buffered
+// records first
+func (l *Logger) finishSegments() error {
+    // A broken file is left unfinished, so readers report it as truncated
+    if l.segments == nil || l.segments.count == 0 || l.file == nil || l.segmentsBroken {
+        return nil
+    }
+    info, statErr := l.file.Stat()
+    if _, err := l.file.Write(l.segments.seal(nil, nil, true)); err != nil {
+        // Cut a partial segment off; readers report the backup as truncated
+        if statErr == nil {
+            l.file.Truncate(info.Size())
+        }
+        l.requestDiskCheck()
+        return fmt.Errorf("failed to write to log file: %v", err)
+    }
+    return nil
+}
@@ -0,0 +1,413 @@
+package logr
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestEncryptedLogs(t *testing.T) {
+    for _, compress := range []bool{false, true} {
+        t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+            // Create temporary directory
+            tempDir := "./test_logs_encrypted"
+            defer os.RemoveAll(tempDir)
+
+            keys := &Keyring{ActiveKeyID: "k1", Keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}}
+            config := &Config{
+                LogDir:         tempDir,
+                FileName:       "encrypted",
+                MaxSize:        500,
+                MaxAge:         time.Hour,
+                MaxBackups:     100,
+                Level:          DEBUG,
+                Compress:       compress,
+                EncryptionKeys: keys,
+                EncryptActive:  true,
+            }
+
+            // Files written before a key rotation stay readable
+            for run, id := range []string{"k1", "k2"} {
+                keys.ActiveKeyID = id
+                keys.Keys[id] = bytes.Repeat([]byte{byte(run + 1)}, 32)
+                logger, err := NewLogger(config)
+                if err != nil {
+                    t.Fatalf("failed to create logger: %v", err)
+                }
+                for i := 0; i < 30; i++ {
+                    logger.Info("secret query %02d", run*30+i)
+                }
+                logger.Close()
+            }
+
+            entries, err := os.ReadDir(tempDir)
+            if err != nil {
+                t.Fatalf("failed to read log directory: %v", err)
+            }
+            if len(entries) < 4 {
+                t.Fatalf("expected several rotated files, got %d", len(entries))
+            }
+            for _, entry := range entries {
+                data, _ := os.ReadFile(filepath.Join(tempDir, entry.Name()))
+                if bytes.Contains(data, []byte("secret")) || !bytes.HasPrefix(data, []byte(encryptionMagic)) {
+                    t.Errorf("%s is not encrypted", entry.Name())
+                }
+            }
+
+            reader, err := OpenReader(config)
+            if err != nil {
+                t.Fatalf("failed to open reader: %v", err)
+            }
+            defer reader.Close()
+            for i := 0; i < 60; i++ {
+                record, err := reader.Next()
+                if err != nil {
+                    t.Fatalf("record %d: %v", i, err)
+                }
+                if expected := fmt.Sprintf("secret query %02d", i); record.Message != expected {
+                    t.Fatalf("expected %q, got %q", expected, record.Message)
+                }
+            }
+            if _, err := reader.Next(); err != io.EOF {
+                t.Errorf("expected io.EOF, got %v", err)
+            }
+
+            files, err := ListLogFiles(config)
+            if err != nil {
+                t.Fatalf("failed to list log files: %v", err)
+            }
+            if first, _, err := files[0].TimeRange(); err != nil || first.IsZero() {
+                t.Errorf("expected the time range of an encrypted file, got %v, %v", first, err)
+            }
+
+            // Without the key the files cannot be read
+            plain, err := NewReader(tempDir, "encrypted")
+            if err != nil {
+                t.Fatalf("failed to open reader: %v", err)
+            }
+            defer plain.Close()
+            if _, err := plain.Next(); !errors.Is(err, ErrEncrypted) {
+                t.Errorf("expected ErrEncrypted, got %v", err)
+            }
+        })
+    }
+}
+
+func TestEncryptedActiveFile(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_encrypted_active"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:         tempDir,
+        FileName:       "active",
+        MaxSize:        1024 * 1024,
+        Level:          DEBUG,
+        EncryptionKeys: &Keyring{ActiveKeyID: "k", Keys: map[string][]byte{"k": bytes.Repeat([]byte{7}, 16)}},
+        EncryptActive:  true,
+    }
+    activePath := filepath.Join(tempDir, "active.log")
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    for i := 0; i < 10; i++ {
+        logger.Info("record %d", i)
+        if i%5 == 4 {
+            logger.Sync()
+        }
+    }
+    logger.Info("unsealed")
+    if got := readMessages(t, config); len(got) != 10 {
+        t.Errorf("expected the 10 sealed records to be readable, got %d", len(got))
+    }
+    logger.Close()
+
+    // A segment torn by a crash is dropped when the file is continued
+    f, _ := os.OpenFile(activePath, os.O_WRONLY|os.O_APPEND, 0644)
+    f.Write([]byte{0, 0, 0, 100, 1, 2, 3})
+    f.Close()
+    logger, err = NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Info("after crash")
+    logger.Close()
+
+    got := readMessages(t, config)
+    if len(got) != 12 || got[10] != "unsealed" || got[11] != "after crash" {
+        t.Errorf("unexpected records after the torn segment: %v", got)
+    }
+    if files, _ := ListLogFiles(config); len(files) != 1 {
+        t.Errorf("expected the active file to be continued, got %d files", len(files))
+    }
+
+    // Turning encryption of the active file off starts a new plain file
+    config.EncryptActive = false
+    logger, err = NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Info("plain")
+    logger.Close()
+
+    if data, _ := os.ReadFile(activePath); bytes.HasPrefix(data, []byte(encryptionMagic)) || !bytes.Contains(data, []byte("plain")) {
+        t.Errorf("expected a plain active file, got %q", data)
+    }
+    if got := readMessages(t, config); len(got) != 13 || got[12] != "plain" {
+        t.Errorf("unexpected records: %v", got)
+    }
+}
+
+func TestTruncatedEncryptedBackup(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_encrypted_truncated"
+    defer os.RemoveAll(tempDir)
+    if err := os.MkdirAll(tempDir, 0755); err != nil {
+        t.Fatalf("failed to create directory: %v", err)
+    }
+    keys := &Keyring{ActiveKeyID: "k", Keys: map[string][]byte{"k": bytes.Repeat([]byte{7}, 16)}}
+
+    // Three segments, the last one final
+    path := filepath.Join(tempDir, "backup.log")
+    content := bytes.Repeat([]byte("0123456789abcdef"), 2*encryptSegmentSize/16+10)
+    os.WriteFile(path, content, 0644)
+    if err := encryptFile(path, path, keys); err != nil {
+        t.Fatalf("failed to encrypt: %v", err)
+    }
+    data, _ := os.ReadFile(path)
+    read := func(data []byte, finished bool) ([]byte, error) {
+        r, err := newDecryptReader(bytes.NewReader(data), keys, finished)
+        if err != nil {
+            return nil, err
+        }
+        return io.ReadAll(r)
+    }
+    if got, err := read(data, true); err != nil || !bytes.Equal(got, content) {
+        t.Fatalf("failed to read the backup back: %v", err)
+    }
+
+    // A backup cut at any segment boundary is truncated, while the active
+    // file may end there
+    boundaries := 0
+    for end := len(encryptionMagic) + 2 + encryptSaltSize; end < len(data); boundaries++ {
+        if _, err := read(data[:end], true); !errors.Is(err, ErrTruncated) {
+            t.Errorf("expected ErrTruncated for a cut at %d, got %v", end, err)
+        }
+        if _, err := read(data[:end], false); err != nil {
+            t.Errorf("expected an active file cut at %d to read, got %v", end, err)
+        }
+        end += 4 + int(binary.BigEndian.Uint32(data[end:]))
+    }
+    if boundaries != 3 {
+        t.Errorf("expected 3 segment boundaries, got %d", boundaries)
+    }
+
+    // Rotation finishes an encrypted active file
+    config := &Config{
+        LogDir:         tempDir,
+        FileName:       "active",
+        MaxSize:        300,
+        MaxBackups:     100,
+        Level:          DEBUG,
+        EncryptionKeys: keys,
+        EncryptActive:  true,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    for i := 0; i < 10; i++ {
+        logger.Info("record %d", i)
+        logger.Sync()
+    }
+    logger.Close()
+    if got := readMessages(t, config); len(got) != 10 {
+        t.Fatalf("expected 10 records, got %d", len(got))
+    }
+
+    // Dropping a backup's final segment is reported
+    files, _ := ListLogFiles(config)
+    backup, _ := os.ReadFile(files[0].Path)
+    os.WriteFile(files[0].Path, backup[:len(backup)-4-16], 0644) // The empty final segment
+    reader, err := OpenReader(config)
+    if err != nil {
+        t.Fatalf("failed to open reader: %v", err)
+    }
+    defer reader.Close()
+    for err == nil {
+        _, err = reader.Next()
+    }
+    if !errors.Is(err, ErrTruncated) {
+        t.Errorf("expected ErrTruncated, got %v", err)
+    }
+}
+
+func TestEncryptedWriteFailure(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_encrypted_failure"
+    defer os.RemoveAll(tempDir)
+
+    keys := &Keyring{ActiveKeyID: "k", Keys: map[string][]byte{"k": bytes.Repeat([]byte{7}, 16)}}
+    config := &Config{
+        LogDir:         tempDir,
+        FileName:       "failing",
+        MaxSize:        1024 * 1024,
+        MaxBackups:     100,
+        Level:          DEBUG,
+        EncryptionKeys: keys,
+        EncryptActive:  true,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    // failSync seals the buffered records into a file that rejects writes
+    failSync := func() {
+        logger.mu.Lock()
+        file := logger.file
+        closed, err := os.Open(file.Name())
+        if err != nil {
+            t.Fatalf("failed to open log file: %v", err)
+        }
+        closed.Close()
+        logger.file = closed
+        logger.mu.Unlock()
+        if err := logger.Sync(); err == nil {
+            t.Error("expected the sync to fail")
+        }
+        logger.mu.Lock()
+        logger.file = file
+        logger.mu.Unlock()
+    }
+
+    // A file with sealed segments is rotated instead of reusing their nonces
+    logger.Info("kept 1")
+    logger.Sync()
+    salt, count := logger.segments.salt, logger.segments.count
+    logger.Info("lost")
+    failSync()
+    if logger.segments.count <= count || !logger.segmentsBroken {
+        t.Errorf("expected the used nonces to stay used, count %d after %d", logger.segments.count, count)
+    }
+    logger.Info("kept 2")
+    logger.Sync()
+    if bytes.Equal(logger.segments.salt, salt) || logger.segmentsBroken {
+        t.Error("expected the records after the failure in a file with a new salt")
+    }
+    if dropped := logger.Stats().Dropped; dropped != 1 {
+        t.Errorf("expected 1 dropped record, got %d", dropped)
+    }
+
+    // The broken backup ends after its last sealed record
+    reader, err := OpenReader(config)
+    if err != nil {
+        t.Fatalf("failed to open reader: %v", err)
+    }
+    defer reader.Close()
+    var messages []string
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            if !errors.Is(err, ErrTruncated) {
+                t.Errorf("expected the broken backup to be truncated, got %v", err)
+            }
+            continue
+        }
+        messages = append(messages, record.Message)
+    }
+    if fmt.Sprint(messages) != "[kept 1 kept 2]" {
+        t.Errorf("expected the kept records, got %v", messages)
+    }
+}
+
+func TestFollowEncrypted(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_follow_encrypted"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:         tempDir,
+        FileName:       "follow_encrypted",
+        MaxSize:        300,
+        MaxAge:         time.Hour,
+        MaxBackups:     1000,
+        Level:          DEBUG,
+        SyncInterval:   time.Millisecond,
+        EncryptionKeys: &Keyring{ActiveKeyID: "k", Keys: map[string][]byte{"k": bytes.Repeat([]byte{9}, 32)}},
+        EncryptActive:  true,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Info("existing 1")
+    logger.Info("existing 2")
+    logger.Sync()
+
+    var mu sync.Mutex
+    var messages []string
+    follower, err := Follow(config, FollowOptions{Lines: 1, PollInterval: time.Millisecond}, func(record *Record) {
+        mu.Lock()
+        defer mu.Unlock()
+        messages = append(messages, record.Message)
+    })
+    if err != nil {
+        t.Fatalf("failed to follow: %v", err)
+    }
+
+    const count = 100
+    for i := 0; i < count; i++ {
+        logger.Info("follow record %03d", i)
+        if i%10 == 0 {
+            time.Sleep(5 * time.Millisecond)
+        }
+    }
+    logger.Close()
+    follower.Close()
+
+    mu.Lock()
+    defer mu.Unlock()
+    if len(messages) != count+1 || messages[0] != "existing 2" {
+        t.Fatalf("expected %d records starting with the last existing one, got %d: %v", count+1, len(messages), messages)
+    }
+    for i, msg := range messages[1:] {
+        if msg != fmt.Sprintf("follow record %03d", i) {
+            t.Fatalf("unexpected record %d: %q", i, msg)
+        }
+    }
+}
+
+// readMessages returns the messages of every readable record of a configuration
+func readMessages(t *testing.T, config *Config) []string {
+    reader, err := OpenReader(config)
+    if err != nil {
+        t.Fatalf("failed to open reader: %v", err)
+    }
+    defer reader.Close()
+
+    var messages []string
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            return messages
+        }
+        if err != nil {
+            t.Fatalf("failed to read: %v", err)
+        }
+        messages = append(messages, record.Message)
+    }
+}
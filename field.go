@@ -0,0 +1,190 @@
+package logr
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// FieldType identifies how a Field's value is stored
+type FieldType uint8
+
+const (
+    StringType FieldType = iota
+    Int64Type
+    DurationType
+    ErrorType
+    TimeType
+    AnyType
+    GroupType
+)
+
+// Field represents a structured key/value pair attached to a log record
+type Field struct {
+    Key       string
+    Type      FieldType
+    Integer   int64
+    String    string
+    Interface interface{}
+}
+
+// String constructs a field with a string value
+func String(key, value string) Field {
+    return Field{Key: key, Type: StringType, String: value}
+}
+
+// Int64 constructs a field with an int64 value
+func Int64(key string, value int64) Field {
+    return Field{Key: key, Type: Int64Type, Integer: value}
+}
+
+// Int constructs a field with an int value
+func Int(key string, value int) Field {
+    return Int64(key, int64(value))
+}
+
+// Duration constructs a field with a time.Duration value
+func Duration(key string, value time.Duration) Field {
+    return Field{Key: key, Type: DurationType, Integer: int64(value)}
+}
+
+// Error constructs a field with the key "error" holding err
+func Error(err error) Field {
+    return NamedError("error", err)
+}
+
+// NamedError constructs a field holding err under the given key
+func NamedError(key string, err error) Field {
+    return Field{Key: key, Type: ErrorType, Interface: err}
+}
+
+// Time constructs a field with a time.Time value
+func Time(key string, value time.Time) Field {
+    return Field{Key: key, Type: TimeType, Interface: value}
+}
+
+// Any constructs a field with an arbitrary value
+func Any(key string, value interface{}) Field {
+    switch v := value.(type) {
+    case string:
+        return String(key, v)
+    case int:
+        return Int(key, v)
+    case int64:
+        return Int64(key, v)
+    case time.Duration:
+        return Duration(key, v)
+    case time.Time:
+        return Time(key, v)
+    case error:
+        return NamedError(key, v)
+    }
+    return Field{Key: key, Type: AnyType, Interface: value}
+}
+
+// Group constructs a field holding nested fields under key. They are encoded
+// as a JSON object, or as key.name=value pairs in text and logfmt; a group
+// with an empty key adds its fields to the enclosing record or group.
+func Group(key string, fields ...Field) Field {
+    return Field{Key: key, Type: GroupType, Interface: fields}
+}
+
+// Value returns the field value as a Go value
+func (f Field) Value() interface{} {
+    switch f.Type {
+    case StringType:
+        return f.String
+    case Int64Type:
+        return f.Integer
+    case DurationType:
+        return time.Duration(f.Integer)
+    default:
+        return f.Interface
+    }
+}
+
+// ValueString returns the field value formatted as text
+func (f Field) ValueString() string {
+    switch f.Type {
+    case StringType:
+        return f.String
+    case Int64Type:
+        return strconv.FormatInt(f.Integer, 10)
+    case DurationType:
+        return time.Duration(f.Integer).String()
+    case ErrorType:
+        if f.Interface == nil {
+            return "<nil>"
+        }
+        return f.Interface.(error).Error()
+    case TimeType:
+        return f.Interface.(time.Time).Format(time.RFC3339Nano)
+    case GroupType:
+        var sb strings.Builder
+        appendFields(&sb, f.Interface.([]Field))
+        return "{" + strings.TrimPrefix(sb.String(), " ") + "}"
+    default:
+        return fmt.Sprint(f.Interface)
+    }
+}
+
+// appendFields appends fields to a text log line as key=value pairs
+func appendFields(sb *strings.Builder, fields []Field) {
+    appendGroupFields(sb, "", fields)
+}
+
+// appendGroupFields appends fields as key=value pairs, flattening groups into
+// keys joined with dots. Keys are sanitized like values are quoted, so that
+// neither can end a record or forge another pair, and top-level keys that
+// collide with ts, level or msg are renamed by fieldKey.
+func appendGroupFields(sb *strings.Builder, prefix string, fields []Field) {
+    for _, f := range fields {
+        if f.Type == GroupType {
+            nested := prefix
+            if f.Key != "" {
+                nested += f.Key + "."
+            }
+            appendGroupFields(sb, nested, f.Interface.([]Field))
+            continue
+        }
+        key := prefix + f.Key
+        if prefix == "" {
+            key = fieldKey(f.Key)
+        }
+        sb.WriteByte(' ')
+        writeLogfmtKey(sb, key)
+        sb.WriteByte('=')
+        writeLogfmtValue(sb, f.ValueString())
+    }
+}
+
+// reservedFieldPrefix is prepended to top-level field keys that collide with
+// the keys of the record itself
+const reservedFieldPrefix = "fields."
+
+// fieldKey returns the key a top-level field is written under: ts, level and
+// msg become fields.ts, fields.level and fields.msg, so that user data can
+// never replace the time, level or message of a record when it is parsed
+func fieldKey(key string) string {
+    switch key {
+    case "ts", "level", "msg":
+        return reservedFieldPrefix + key
+    }
+    return key
+}
+
+// needsQuoting reports whether a field value must be quoted in text output,
+// which escapes the characters messages escape as well
+func needsQuoting(s string) bool {
+    if s == "" {
+        return true
+    }
+    for _, r := range s {
+        if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || needsTextEscape(r) {
+            return true
+        }
+    }
+    return false
+}
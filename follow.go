@@ -0,0 +1,545 @@
+package logr
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+)
+
+const defaultPollInterval = 100 * time.Millisecond
+
+// FollowOptions configures Follow
+type FollowOptions struct {
+    FromStart    bool          // Also deliver the records already in the active file
+    Lines        int           // Also deliver this many of the last records already in the active file
+    PollInterval time.Duration // How often to check for new records, 100ms by default
+}
+
+// maxEncryptionHeader is the size of the longest encryption header
+const maxEncryptionHeader = len(encryptionMagic) + 1 + 255 + encryptSaltSize
+
+// Follower streams records appended to the active log file, like tail -F.
+// When the file is rotated, the rest of it is read through the still open
+// file descriptor, even if compression has already removed it, and any
+// backups rotated in between are read before the new active file.
+// Records of an encrypted active file are delivered once their segment is sealed.
+type Follower struct {
+    dir     string
+    path    string
+    namer   *backupNamer
+    options FollowOptions
+    fn      func(*Record)
+    keys    KeyProvider
+
+    file    *os.File
+    info    os.FileInfo // Identity of file
+    offset  int64       // Bytes of file consumed, including pending
+    pending []byte      // Incomplete last line
+    record  string      // Last complete record, until no continuation line can follow
+    lines   int         // Lines of record, 0 if there is none
+    line    int         // Lines delivered from file, -1 if unknown
+    maxSeq  uint64      // Highest backup sequence number already accounted for
+    rotated os.FileInfo // The previous active file, until its backup is found
+    cipher  *segmentCipher // Cipher of an encrypted file, once its header is read
+
+    // Records already in an encrypted file are decrypted from its start and
+    // held back, keeping only the last Lines, until holdEnd is reached
+    holding bool
+    holdEnd int64
+    held    []*Record
+
+    stopChan  chan struct{}
+    done      chan struct{}
+    closeOnce sync.Once
+}
+
+// Follow starts streaming records appended to the active log file of config
+// to fn, which is called from a single goroutine. Lines that cannot be parsed
+// are delivered with only Raw and Message set.
+func Follow(config *Config, options FollowOptions, fn func(*Record)) (*Follower, error) {
+    namer, err := newBackupNamer(config.FileName, config.BackupNameTemplate)
+    if err != nil {
+        return nil, err
+    }
+    if options.PollInterval <= 0 {
+        options.PollInterval = defaultPollInterval
+    }
+
+    f := &Follower{
+        dir:      config.LogDir,
+        path:     filepath.Join(config.LogDir, namer.name+".log"),
+        namer:    namer,
+        options:  options,
+        fn:       fn,
+        keys:     config.EncryptionKeys,
+        stopChan: make(chan struct{}),
+        done:     make(chan struct{}),
+    }
+    if err := f.start(); err != nil {
+        return nil, err
+    }
+
+    go f.followRoutine()
+    return f, nil
+}
+
+// start opens the active file, making sure no rotation happened between
+// listing the backups and opening it
+func (f *Follower) start() error {
+    for {
+        before, err := f.highestSeq()
+        if err != nil {
+            return err
+        }
+        if err := f.openActive(); err != nil {
+            return err
+        }
+        after, err := f.highestSeq()
+        if err != nil {
+            return err
+        }
+        if before == after {
+            f.maxSeq = after
+            break
+        }
+        f.closeFile()
+    }
+
+    if f.file != nil && !f.options.FromStart {
+        if isEncrypted(f.file) {
+            f.holding, f.holdEnd = true, f.info.Size()
+            return nil
+        }
+        f.offset = tailOffset(f.file, f.info.Size(), f.options.Lines)
+        f.line = -1
+    }
+    return nil
+}
+
+// Close stops following after delivering the records written so far
+func (f *Follower) Close() error {
+    f.closeOnce.Do(func() {
+        close(f.stopChan)
+        <-f.done
+    })
+    return nil
+}
+
+// followRoutine is the goroutine that polls the active file
+func (f *Follower) followRoutine() {
+    defer close(f.done)
+    defer f.closeFile()
+
+    ticker := time.NewTicker(f.options.PollInterval)
+    defer ticker.Stop()
+
+    for {
+        f.poll()
+        select {
+        case <-ticker.C:
+        case <-f.stopChan:
+            f.poll()
+            return
+        }
+    }
+}
+
+// poll delivers new records and follows the active file across rotations
+func (f *Follower) poll() {
+    if f.file == nil {
+        // The new active file may not have been created yet
+        if err := f.switchFile(); err != nil {
+            fmt.Fprintf(diagnostics, "failed to follow log file: %v\n", err)
+        }
+        if f.file == nil {
+            return
+        }
+    }
+    if err := f.drain(); err != nil {
+        fmt.Fprintf(diagnostics, "failed to follow log file: %v\n", err)
+        return
+    }
+
+    info, err := os.Stat(f.path)
+    if err == nil && os.SameFile(info, f.info) {
+        if info.Size() < f.offset {
+            // Truncated in place; start over
+            f.offset, f.pending, f.line, f.cipher = 0, nil, 0, nil
+            f.record, f.lines = "", 0
+        }
+        return
+    }
+    if err != nil && !os.IsNotExist(err) {
+        fmt.Fprintf(diagnostics, "failed to follow log file: %v\n", err)
+        return
+    }
+
+    // The active file was rotated away; whatever it holds now is final
+    if err := f.drain(); err != nil {
+        fmt.Fprintf(diagnostics, "failed to follow log file: %v\n", err)
+    }
+    if len(f.pending) > 0 {
+        last := append(f.pending, '\n')
+        f.pending = nil
+        f.pushLines(last)
+        f.flushRecord()
+    }
+    f.rotated = f.info
+    f.closeFile()
+    if err := f.switchFile(); err != nil {
+        fmt.Fprintf(diagnostics, "failed to follow log file: %v\n", err)
+        return
+    }
+
+    // Deliver what the new active file already holds, as the last poll
+    // before closing would otherwise miss it
+    if f.file != nil {
+        if err := f.drain(); err != nil {
+            fmt.Fprintf(diagnostics, "failed to follow log file: %v\n", err)
+        }
+    }
+}
+
+// drain reads the active file to its current end and delivers complete lines
+func (f *Follower) drain() error {
+    if f.offset == 0 && f.cipher == nil {
+        encrypted, err := f.readEncryptionHeader()
+        if err != nil || encrypted {
+            return err
+        }
+    }
+    if f.cipher != nil {
+        return f.drainSegments()
+    }
+
+    buf := make([]byte, 64*1024)
+    for {
+        n, err := f.file.ReadAt(buf, f.offset)
+        f.offset += int64(n)
+        f.pushLines(buf[:n])
+        if err == io.EOF || (err == nil && n == 0) {
+            // Records are written whole, so no continuation line is missing
+            f.flushRecord()
+            return nil
+        }
+        if err != nil {
+            return err
+        }
+    }
+}
+
+// pushLines appends data to the incomplete last line and delivers complete
+// records, joining continuation lines to the record they continue
+func (f *Follower) pushLines(data []byte) {
+    f.pending = append(f.pending, data...)
+    for {
+        i := bytes.IndexByte(f.pending, '\n')
+        if i < 0 {
+            break
+        }
+        line := string(f.pending[:i])
+        f.pending = f.pending[i+1:]
+        if f.lines > 0 && strings.HasPrefix(line, ContinuationMarker) {
+            f.record += "\n" + line
+            f.lines++
+            continue
+        }
+        f.flushRecord()
+        f.record, f.lines = line, 1
+    }
+}
+
+// flushRecord delivers the last complete record
+func (f *Follower) flushRecord() {
+    if f.lines == 0 {
+        return
+    }
+    f.deliver(f.record, f.lines)
+    f.record, f.lines = "", 0
+}
+
+// readEncryptionHeader checks whether the active file is encrypted and, if
+// it is, reads its header once it is complete and continues drainSegments
+// from there. It reports whether the file is or may still turn out to be
+// encrypted.
+func (f *Follower) readEncryptionHeader() (bool, error) {
+    buf := make([]byte, maxEncryptionHeader)
+    n, err := f.file.ReadAt(buf, 0)
+    if err != nil && err != io.EOF {
+        return false, err
+    }
+    buf = buf[:n]
+    if n < len(encryptionMagic) {
+        // Wait for more of the file, unless it is already plain
+        return strings.HasPrefix(encryptionMagic, string(buf)), nil
+    }
+    if string(buf[:len(encryptionMagic)]) != encryptionMagic {
+        return false, nil
+    }
+    if n < len(encryptionMagic)+1 || n < len(encryptionMagic)+1+int(buf[len(encryptionMagic)])+encryptSaltSize {
+        return true, nil
+    }
+    c, err := readEncryptionHeader(bytes.NewReader(buf), f.keys)
+    if err != nil {
+        return true, fmt.Errorf("failed to decrypt %s: %w", f.path, err)
+    }
+    f.cipher, f.offset = c, int64(len(c.header))
+    return true, f.drainSegments()
+}
+
+// drainSegments decrypts the segments sealed in the active file since the
+// last poll and delivers their complete lines
+func (f *Follower) drainSegments() error {
+    for {
+        var prefix [4]byte
+        if n, _ := f.file.ReadAt(prefix[:], f.offset); n < len(prefix) {
+            break
+        }
+        size := binary.BigEndian.Uint32(prefix[:])
+        if size > uint32(f.cipher.maxSegment()) {
+            return fmt.Errorf("invalid encrypted segment in %s", f.path)
+        }
+        segment := make([]byte, size)
+        if n, _ := f.file.ReadAt(segment, f.offset+4); n < len(segment) {
+            break
+        }
+        plaintext, _, err := f.cipher.open(segment)
+        if err != nil {
+            return fmt.Errorf("failed to decrypt %s: %w", f.path, err)
+        }
+        f.offset += 4 + int64(size)
+        f.pushLines(plaintext)
+        if f.holding && f.offset >= f.holdEnd {
+            f.flushRecord()
+            f.releaseHeld()
+        }
+    }
+    f.flushRecord()
+    return nil
+}
+
+// releaseHeld delivers the held records once the records that were in the
+// file when following started have been decrypted
+func (f *Follower) releaseHeld() {
+    f.holding = false
+    for _, record := range f.held {
+        f.fn(record)
+    }
+    f.held = nil
+}
+
+// switchFile reads the backups rotated since the active file was opened and
+// then opens the new active file, repeating if it rotated again meanwhile
+func (f *Follower) switchFile() error {
+    for {
+        if err := f.readBackups(); err != nil {
+            return err
+        }
+        if err := f.openActive(); err != nil || f.file == nil {
+            return err
+        }
+        seq, err := f.highestSeq()
+        if err != nil {
+            return err
+        }
+        if seq == f.maxSeq {
+            return nil
+        }
+        f.closeFile()
+    }
+}
+
+// readBackups delivers the records of backups newer than maxSeq, skipping the
+// backup of the file that was just drained
+func (f *Follower) readBackups() error {
+    files, err := listLogFiles(f.dir, f.namer)
+    if err != nil {
+        return err
+    }
+
+    // A backup may be listed both plain and compressed while it is compressed
+    var backups []logFile
+    for _, file := range files {
+        if file.active || file.seq <= f.maxSeq {
+            continue
+        }
+        if n := len(backups); n > 0 && backups[n-1].seq == file.seq {
+            continue
+        }
+        backups = append(backups, file)
+    }
+
+    for _, backup := range backups {
+        f.maxSeq = backup.seq
+        if f.rotated != nil {
+            // The oldest new backup is the drained file, unless it is still
+            // uncompressed and evidently a different one
+            drained := strings.HasSuffix(backup.name, ".gz")
+            if info, err := os.Stat(backup.path); os.IsNotExist(err) || (err == nil && os.SameFile(info, f.rotated)) {
+                // Compressed since it was listed, or still the drained file
+                drained = true
+            }
+            if drained {
+                f.rotated = nil
+                continue
+            }
+        }
+        if err := f.readBackup(backup); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// readBackup delivers every record of a backup that was rotated between polls
+func (f *Follower) readBackup(backup logFile) error {
+    file, err := os.Open(backup.path)
+    if os.IsNotExist(err) && !strings.HasSuffix(backup.name, ".gz") {
+        // Compression published the archive and removed the source meanwhile
+        backup.name += ".gz"
+        backup.path += ".gz"
+        file, err = os.Open(backup.path)
+    }
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("failed to open %s: %v", backup.path, err)
+    }
+
+    reader := &Reader{keys: f.keys}
+    if err := reader.openFile(file, backup); err != nil {
+        return err
+    }
+    defer reader.Close()
+
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            return nil
+        }
+        if perr, ok := err.(*ParseError); ok {
+            f.fn(&Record{Entry: Entry{Message: perr.Raw}, File: perr.File, Line: perr.Line, Raw: perr.Raw})
+            continue
+        }
+        if err != nil {
+            return err
+        }
+        f.fn(record)
+    }
+}
+
+// openActive opens the active file from its start, leaving f.file nil if it does not exist
+func (f *Follower) openActive() error {
+    file, err := os.Open(f.path)
+    if os.IsNotExist(err) {
+        return nil
+    }
+    if err != nil {
+        return fmt.Errorf("failed to open %s: %v", f.path, err)
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return fmt.Errorf("failed to stat %s: %v", f.path, err)
+    }
+    f.file, f.info = file, info
+    f.offset, f.pending, f.line, f.cipher = 0, nil, 0, nil
+    f.record, f.lines = "", 0
+    return nil
+}
+
+// highestSeq returns the highest backup sequence number in the log directory
+func (f *Follower) highestSeq() (uint64, error) {
+    files, err := listLogFiles(f.dir, f.namer)
+    if err != nil {
+        return 0, err
+    }
+    var seq uint64
+    for _, file := range files {
+        if !file.active && file.seq > seq {
+            seq = file.seq
+        }
+    }
+    return seq, nil
+}
+
+// deliver parses a record of the active file, spanning lines lines, and
+// passes it to the callback
+func (f *Follower) deliver(raw string, lines int) {
+    line := 0
+    if f.line >= 0 {
+        line = f.line + 1
+        f.line += lines
+    }
+
+    raw = strings.TrimSuffix(raw, "\r")
+    if raw == "" {
+        return
+    }
+    record := &Record{File: f.path, Line: line, Raw: raw}
+    if entry, err := ParseLine(raw); err == nil {
+        record.Entry = *entry
+    } else {
+        record.Message = raw
+    }
+    if f.holding {
+        f.held = append(f.held, record)
+        if len(f.held) > f.options.Lines {
+            f.held = f.held[1:]
+        }
+        return
+    }
+    f.fn(record)
+}
+
+// tailOffset returns the offset of the last n records of a file
+func tailOffset(f io.ReaderAt, size int64, n int) int64 {
+    if n <= 0 {
+        return size
+    }
+
+    buf := make([]byte, 4096)
+    end := size - 1 // Ignore the newline ending the last line
+    for end > 0 {
+        start := end - int64(len(buf))
+        if start < 0 {
+            start = 0
+        }
+        chunk := buf[:end-start]
+        if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+            return size
+        }
+        for i := len(chunk) - 1; i >= 0; i-- {
+            if chunk[i] != '\n' || continuesAt(f, start+int64(i)+1) {
+                continue
+            }
+            if n--; n == 0 {
+                return start + int64(i) + 1
+            }
+        }
+        end = start
+    }
+    return 0
+}
+
+// continuesAt reports whether a continuation line starts at offset
+func continuesAt(f io.ReaderAt, offset int64) bool {
+    marker := make([]byte, len(ContinuationMarker))
+    n, _ := f.ReadAt(marker, offset)
+    return n == len(marker) && string(marker) == ContinuationMarker
+}
+
+// closeFile closes the active file
+func (f *Follower) closeFile() {
+    if f.file != nil {
+        f.file.Close()
+        f.file = nil
+    }
+}
@@ -0,0 +1,152 @@
+package logr
+
+import (
+    "fmt"
+    "os"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestFollowAcrossRotation(t *testing.T) {
+    for _, compress := range []bool{false, true} {
+        t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+            // Create temporary directory
+            tempDir := "./test_logs_follow"
+            defer os.RemoveAll(tempDir)
+
+            config := &Config{
+                LogDir:     tempDir,
+                FileName:   "follow_test",
+                MaxSize:    300,
+                MaxAge:     time.Hour,
+                MaxBackups: 1000,
+                Level:      DEBUG,
+                Compress:   compress,
+            }
+
+            logger, err := NewLogger(config)
+            if err != nil {
+                t.Fatalf("failed to create logger: %v", err)
+            }
+            logger.Info("written before following")
+
+            var mu sync.Mutex
+            var messages []string
+            follower, err := Follow(config, FollowOptions{PollInterval: time.Millisecond}, func(record *Record) {
+                mu.Lock()
+                defer mu.Unlock()
+                messages = append(messages, record.Message)
+            })
+            if err != nil {
+                t.Fatalf("failed to follow: %v", err)
+            }
+
+            // Bursts rotate several times between polls, pauses only once
+            const count = 300
+            for i := 0; i < count; i++ {
+                logger.Info("follow record %03d", i)
+                if i%25 == 0 {
+                    time.Sleep(5 * time.Millisecond)
+                }
+            }
+            logger.Close()
+            follower.Close()
+
+            mu.Lock()
+            defer mu.Unlock()
+            if len(messages) != count {
+                t.Fatalf("expected %d records, got %d", count, len(messages))
+            }
+            for i, msg := range messages {
+                if msg != fmt.Sprintf("follow record %03d", i) {
+                    t.Fatalf("unexpected record %d: %q", i, msg)
+                }
+            }
+        })
+    }
+}
+
+func TestFollowFinalPollAfterRotation(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_follow_final"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:   tempDir,
+        FileName: "follow_final",
+        MaxSize:  300,
+        Level:    DEBUG,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    // Only the first poll and the one on Close run, so the latter finds the
+    // file rotated away and must still deliver the new active file
+    var mu sync.Mutex
+    var messages []string
+    follower, err := Follow(config, FollowOptions{PollInterval: time.Hour}, func(record *Record) {
+        mu.Lock()
+        defer mu.Unlock()
+        messages = append(messages, record.Message)
+    })
+    if err != nil {
+        t.Fatalf("failed to follow: %v", err)
+    }
+    time.Sleep(20 * time.Millisecond)
+    const count = 20
+    for i := 0; i < count; i++ {
+        logger.Info("final record %02d", i)
+    }
+    logger.Close()
+    follower.Close()
+
+    mu.Lock()
+    defer mu.Unlock()
+    if len(messages) != count || messages[count-1] != fmt.Sprintf("final record %02d", count-1) {
+        t.Errorf("expected %d records ending with the last one, got %q", count, messages)
+    }
+}
+
+func TestFollowLastLines(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_follow_lines"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:   tempDir,
+        FileName: "follow_lines",
+        MaxSize:  1024 * 1024,
+        Level:    DEBUG,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+    for i := 0; i < 10; i++ {
+        logger.Info("existing %d", i)
+    }
+
+    var mu sync.Mutex
+    var messages []string
+    follower, err := Follow(config, FollowOptions{Lines: 3, PollInterval: time.Millisecond}, func(record *Record) {
+        mu.Lock()
+        defer mu.Unlock()
+        messages = append(messages, record.Message)
+    })
+    if err != nil {
+        t.Fatalf("failed to follow: %v", err)
+    }
+    logger.Info("new")
+    follower.Close()
+
+    mu.Lock()
+    defer mu.Unlock()
+    expected := []string{"existing 7", "existing 8", "existing 9", "new"}
+    if fmt.Sprint(messages) != fmt.Sprint(expected) {
+        t.Errorf("expected %v, got %v", expected, messages)
+    }
+}
@@ -0,0 +1,380 @@
+package logr
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "encoding/binary"
+    "errors"
+    "io"
+    "os"
+    "strings"
+    "time"
+)
+
+const (
+    indexInterval    = 64 * 1024 // Uncompressed bytes between index checkpoints
+    indexVersion     = 1
+    indexSubfieldLen = 2 + 2 // Subfield ID and length in the gzip extra field
+    indexHeaderLen   = 1 + 8 + 8
+    checkpointLen    = 8 + 8 + 8
+    maxCheckpoints   = (0xffff - indexSubfieldLen - indexHeaderLen) / checkpointLen
+)
+
+// indexSubfieldID identifies the time index in the gzip extra field
+var indexSubfieldID = [2]byte{'L', 'X'}
+
+// fileIndex is the time index of a compressed backup. It is stored in the
+// extra field of an empty first gzip member; every checkpoint starts a new
+// gzip member, so a reader can seek straight to it.
+type fileIndex struct {
+    first       time.Time // Timestamp of the first record
+    last        time.Time // Latest timestamp in the file
+    checkpoints []checkpoint
+}
+
+// checkpoint locates the record starting a gzip member
+type checkpoint struct {
+    time   time.Time
+    offset int64 // Compressed offset of the member, or the uncompressed offset while building
+    line   int64 // 1-based line number of the record
+}
+
+// lineTime extracts the timestamp of a text, JSON or logfmt record without
+// parsing the whole line
+func lineTime(line string) (time.Time, bool) {
+    var ts time.Time
+    var err error
+    switch {
+    case strings.HasPrefix(line, "[") && len(line) > len(textTimeLayout)+1 && line[len(textTimeLayout)+1] == ']':
+        ts, err = time.ParseInLocation(textTimeLayout, line[1:len(textTimeLayout)+1], time.Local)
+    case strings.HasPrefix(line, `{"ts":"`):
+        value := line[len(`{"ts":"`):]
+        end := strings.IndexByte(value, '"')
+        if end < 0 {
+            return ts, false
+        }
+        ts, err = time.Parse(jsonTimeLayout, value[:end])
+    case strings.HasPrefix(line, "ts="):
+        value := line[len("ts="):]
+        if end := strings.IndexByte(value, ' '); end >= 0 {
+            value = value[:end]
+        }
+        ts, err = time.Parse(jsonTimeLayout, value)
+    default:
+        return ts, false
+    }
+    return ts, err == nil
+}
+
+// scanIndex reads an uncompressed log file and chooses checkpoints at record
+// boundaries roughly every indexInterval bytes, with uncompressed offsets
+func scanIndex(r io.Reader) (*fileIndex, error) {
+    idx := &fileIndex{}
+    br := bufio.NewReaderSize(r, 64*1024)
+    var offset, line int64
+    for {
+        raw, err := br.ReadString('\n')
+        if len(raw) > 0 {
+            line++
+            ts, ok := lineTime(raw)
+            if ok {
+                if idx.first.IsZero() {
+                    idx.first = ts
+                }
+                if ts.After(idx.last) {
+                    idx.last = ts
+                }
+            }
+
+            // The first member always starts at the beginning of the file;
+            // later ones only at lines that start a record
+            switch {
+            case len(idx.checkpoints) == 0:
+                idx.checkpoints = append(idx.checkpoints, checkpoint{time: ts, line: line})
+            case ok && offset-idx.checkpoints[len(idx.checkpoints)-1].offset >= indexInterval:
+                idx.checkpoints = append(idx.checkpoints, checkpoint{time: ts, offset: offset, line: line})
+            }
+            offset += int64(len(raw))
+        }
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    // Thin out checkpoints until the index fits the gzip extra field
+    for len(idx.checkpoints) > maxCheckpoints {
+        thinned := idx.checkpoints[:0]
+        for i := 0; i < len(idx.checkpoints); i += 2 {
+            thinned = append(thinned, idx.checkpoints[i])
+        }
+        idx.checkpoints = thinned
+    }
+    return idx, nil
+}
+
+// marshal encodes the index as a gzip extra field
+func (idx *fileIndex) marshal() []byte {
+    data := make([]byte, 0, indexSubfieldLen+indexHeaderLen+len(idx.checkpoints)*checkpointLen)
+    data = append(data, indexSubfieldID[0], indexSubfieldID[1])
+    data = appendUint16(data, uint16(indexHeaderLen+len(idx.checkpoints)*checkpointLen))
+    data = append(data, indexVersion)
+    data = appendUint64(data, uint64(unixNano(idx.first)))
+    data = appendUint64(data, uint64(unixNano(idx.last)))
+    for _, cp := range idx.checkpoints {
+        data = appendUint64(data, uint64(unixNano(cp.time)))
+        data = appendUint64(data, uint64(cp.offset))
+        data = appendUint64(data, uint64(cp.line))
+    }
+    return data
+}
+
+// unmarshalIndex decodes an index from a gzip extra field
+func unmarshalIndex(extra []byte) (*fileIndex, error) {
+    for len(extra) >= indexSubfieldLen {
+        id := [2]byte{extra[0], extra[1]}
+        size := int(binary.BigEndian.Uint16(extra[2:4]))
+        extra = extra[indexSubfieldLen:]
+        if size > len(extra) {
+            break
+        }
+        data := extra[:size]
+        extra = extra[size:]
+        if id != indexSubfieldID {
+            continue
+        }
+        if len(data) < indexHeaderLen || data[0] != indexVersion || (len(data)-indexHeaderLen)%checkpointLen != 0 {
+            return nil, errors.New("unsupported time index")
+        }
+
+        idx := &fileIndex{
+            first: fromUnixNano(binary.BigEndian.Uint64(data[1:9])),
+            last:  fromUnixNano(binary.BigEndian.Uint64(data[9:17])),
+        }
+        for data = data[indexHeaderLen:]; len(data) > 0; data = data[checkpointLen:] {
+            idx.checkpoints = append(idx.checkpoints, checkpoint{
+                time:   fromUnixNano(binary.BigEndian.Uint64(data[0:8])),
+                offset: int64(binary.BigEndian.Uint64(data[8:16])),
+                line:   int64(binary.BigEndian.Uint64(data[16:24])),
+            })
+        }
+        return idx, nil
+    }
+    return nil, errors.New("no time index")
+}
+
+// indexMember returns the empty gzip member carrying the index
+func (idx *fileIndex) indexMember() ([]byte, error) {
+    var buf bytes.Buffer
+    zw := gzip.NewWriter(&buf)
+    zw.Extra = idx.marshal()
+    if err := zw.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+// readIndex reads the time index of a compressed backup
+func readIndex(f io.ReadSeeker) (*fileIndex, error) {
+    if _, err := f.Seek(0, io.SeekStart); err != nil {
+        return nil, err
+    }
+    zr, err := gzip.NewReader(f)
+    if err != nil {
+        return nil, err
+    }
+    return unmarshalIndex(zr.Extra)
+}
+
+// TimeRange returns the timestamps of the first and the latest record in the
+// file, from the time index if it has one and by scanning it otherwise.
+// Encrypted files are decrypted with the EncryptionKeys given to ListLogFiles.
+func (f LogFile) TimeRange() (first, last time.Time, err error) {
+    file, err := os.Open(f.Path)
+    if err != nil {
+        return first, last, err
+    }
+    defer file.Close()
+
+    var src io.Reader = file
+    if isEncrypted(file) {
+        if src, err = newDecryptReader(file, f.keys, !f.Active); err != nil {
+            return first, last, err
+        }
+        if f.Compressed() {
+            if src, err = gzip.NewReader(src); err != nil {
+                return first, last, err
+            }
+        }
+    } else if f.Compressed() {
+        if idx, err := readIndex(file); err == nil {
+            return idx.first, idx.last, nil
+        }
+        if _, err := file.Seek(0, io.SeekStart); err != nil {
+            return first, last, err
+        }
+        zr, err := gzip.NewReader(file)
+        if err != nil {
+            return first, last, err
+        }
+        src = zr
+    }
+    idx, err := scanIndex(src)
+    if err != nil {
+        return first, last, err
+    }
+    return idx.first, idx.last, nil
+}
+
+// seek returns the checkpoint to start reading from to find the first record at or after from
+func (idx *fileIndex) seek(from time.Time) checkpoint {
+    start := idx.checkpoints[0]
+    for _, cp := range idx.checkpoints[1:] {
+        if !cp.time.Before(from) {
+            break
+        }
+        start = cp
+    }
+    return start
+}
+
+// seekPlain binary searches an uncompressed log file for a record boundary
+// from which reading finds every record at or after from
+func seekPlain(f io.ReaderAt, size int64, from time.Time) int64 {
+    var lo, hi int64 = 0, size
+    for hi-lo > 4096 {
+        mid := lo + (hi-lo)/2
+        start, ts, ok := nextRecordAt(f, mid, hi)
+        if !ok || !ts.Before(from) {
+            hi = mid
+        } else {
+            lo = start
+        }
+    }
+    return lo
+}
+
+// nextRecordAt finds the first record starting after offset and before limit
+func nextRecordAt(f io.ReaderAt, offset, limit int64) (int64, time.Time, bool) {
+    br := bufio.NewReader(io.NewSectionReader(f, offset, limit-offset))
+
+    // Skip the partial line at offset
+    skipped, err := br.ReadString('\n')
+    if err != nil {
+        return 0, time.Time{}, false
+    }
+    start := offset + int64(len(skipped))
+    for start < limit {
+        raw, err := br.ReadString('\n')
+        if ts, ok := lineTime(raw); ok {
+            return start, ts, true
+        }
+        if err != nil {
+            break
+        }
+        start += int64(len(raw))
+    }
+    return 0, time.Time{}, false
+}
+
+// compressIndexed writes the plaintext returned by openSource, which is called
+// twice, to dst as an indexed multi-member gzip archive
+func compressIndexed(openSource func() (io.Reader, error), dst *os.File) error {
+    src, err := openSource()
+    if err != nil {
+        return err
+    }
+    idx, err := scanIndex(src)
+    if err != nil {
+        return err
+    }
+    if src, err = openSource(); err != nil {
+        return err
+    }
+
+    // Reserve room for the index member; its size only depends on the checkpoint count
+    reserved, err := idx.indexMember()
+    if err != nil {
+        return err
+    }
+    base := int64(len(reserved))
+    if _, err := dst.Seek(base, io.SeekStart); err != nil {
+        return err
+    }
+
+    // Start a new gzip member at every checkpoint
+    cw := &countingWriter{w: dst}
+    zw := gzip.NewWriter(cw)
+    for i := range idx.checkpoints {
+        var err error
+        zw.Reset(cw)
+        start := idx.checkpoints[i].offset
+        idx.checkpoints[i].offset = base + cw.n
+        if i+1 < len(idx.checkpoints) {
+            _, err = io.CopyN(zw, src, idx.checkpoints[i+1].offset-start)
+        } else {
+            _, err = io.Copy(zw, src)
+        }
+        if err != nil {
+            return err
+        }
+        if err := zw.Close(); err != nil {
+            return err
+        }
+    }
+
+    header, err := idx.indexMember()
+    if err != nil {
+        return err
+    }
+    if len(header) != len(reserved) {
+        return errors.New("time index size changed while compressing")
+    }
+    _, err = dst.WriteAt(header, 0)
+    return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+    w io.Writer
+    n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    c.n += int64(n)
+    return n, err
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+    return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+    return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+    var buf [8]byte
+    binary.BigEndian.PutUint64(buf[:], v)
+    return append(b, buf[:]...)
+}
+
+// unixNano returns t in nanoseconds since the epoch, 0 for the zero time
+func unixNano(t time.Time) int64 {
+    if t.IsZero() {
+        return 0
+    }
+    return t.UnixNano()
+}
+
+// fromUnixNano is the inverse of unixNano
+func fromUnixNano(v uint64) time.Time {
+    if v == 0 {
+        return time.Time{}
+    }
+    return time.Unix(0, int64(v))
+}
@@ -0,0 +1,183 @@
+package logr
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "sync/atomic"
+)
+
+// NameKey is the field holding the name of a named logger
+const NameKey = "logger"
+
+// Named returns a child logger named after its parent's name and name,
+// joined with a dot, e.g. logger.Named("storage").Named("wal") is named
+// "storage.wal". The name is written as the "logger" field of its records
+// and selects its level among the component levels, falling back to the
+// root level.
+func (l *Logger) Named(name string) *Logger {
+    if name == "" {
+        return l
+    }
+    fields := l.fields
+    if l.name != "" {
+        name = l.name + "." + name
+        fields = fields[1:] // The parent's name field
+    }
+    return &Logger{
+        loggerState: l.loggerState,
+        fields:      append([]Field{String(NameKey, name)}, fields...),
+        name:        name,
+        level:       l.componentLevel(name),
+    }
+}
+
+// Name returns the logger's name, empty for the root logger and its With
+// children
+func (l *Logger) Name() string {
+    return l.name
+}
+
+// componentLevel returns the effective level shared by the loggers named
+// name, registering the name on first use
+func (l *Logger) componentLevel(name string) *int32 {
+    l.levelMu.Lock()
+    defer l.levelMu.Unlock()
+    level, ok := l.components[name]
+    if !ok {
+        level = new(int32)
+        *level = int32(l.resolveLevel(name))
+        l.components[name] = level
+    }
+    return level
+}
+
+// SetComponentLevel sets the level of the loggers named pattern. A pattern
+// ending in ".*" sets the level of every logger below the prefix, e.g.
+// "storage.*" covers "storage.wal" and "storage.wal.sync" but not "storage"
+// itself, and "*" covers every named logger. An exact name takes precedence
+// over patterns, and longer patterns over shorter ones.
+func (l *Logger) SetComponentLevel(pattern string, level LogLevel) error {
+    if err := validatePattern(pattern); err != nil {
+        return err
+    }
+    l.levelMu.Lock()
+    defer l.levelMu.Unlock()
+    l.levelPatterns[pattern] = level
+    l.updateComponentLevels()
+    return nil
+}
+
+// ResetComponentLevel removes the level set for pattern, so that the loggers
+// it covered fall back to other patterns or the root level
+func (l *Logger) ResetComponentLevel(pattern string) {
+    l.levelMu.Lock()
+    defer l.levelMu.Unlock()
+    delete(l.levelPatterns, pattern)
+    l.updateComponentLevels()
+}
+
+// ComponentLevels returns the levels set by name or pattern
+func (l *Logger) ComponentLevels() map[string]LogLevel {
+    l.levelMu.Lock()
+    defer l.levelMu.Unlock()
+    levels := make(map[string]LogLevel, len(l.levelPatterns))
+    for pattern, level := range l.levelPatterns {
+        levels[pattern] = level
+    }
+    return levels
+}
+
+// Components returns the names of the named loggers created so far, sorted
+func (l *Logger) Components() []string {
+    l.levelMu.Lock()
+    defer l.levelMu.Unlock()
+    names := make([]string, 0, len(l.components))
+    for name := range l.components {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// loadRootLevel returns the root level shared by every logger of the state
+func (l *Logger) loadRootLevel() LogLevel {
+    return LogLevel(atomic.LoadInt32(&l.rootLevel))
+}
+
+// setRootLevel sets the root level and updates the named loggers following it
+func (l *Logger) setRootLevel(level LogLevel) {
+    l.levelMu.Lock()
+    defer l.levelMu.Unlock()
+    atomic.StoreInt32(&l.rootLevel, int32(level))
+    l.updateComponentLevels()
+}
+
+// effectiveLevels returns the effective level of every named logger by name
+func (l *Logger) effectiveLevels() map[string]LogLevel {
+    l.levelMu.Lock()
+    defer l.levelMu.Unlock()
+    levels := make(map[string]LogLevel, len(l.components))
+    for name, level := range l.components {
+        levels[name] = LogLevel(atomic.LoadInt32(level))
+    }
+    return levels
+}
+
+// resolveLevel returns the level of the loggers named name. levelMu must be
+// held.
+func (l *Logger) resolveLevel(name string) LogLevel {
+    if level, ok := l.levelPatterns[name]; ok {
+        return level
+    }
+    best, level := -1, l.loadRootLevel()
+    for pattern, patternLevel := range l.levelPatterns {
+        prefix := strings.TrimSuffix(pattern, "*")
+        if prefix != pattern && strings.HasPrefix(name, prefix) && len(prefix) > best {
+            best, level = len(prefix), patternLevel
+        }
+    }
+    return level
+}
+
+// updateComponentLevels recomputes the effective level of every named logger
+// after a level change. levelMu must be held.
+func (l *Logger) updateComponentLevels() {
+    for name, level := range l.components {
+        atomic.StoreInt32(level, int32(l.resolveLevel(name)))
+    }
+}
+
+// validatePattern checks that a pattern is a name or a prefix ending in ".*"
+func validatePattern(pattern string) error {
+    if pattern == "" || (strings.Contains(pattern, "*") && pattern != "*" && !strings.HasSuffix(pattern, ".*")) ||
+        strings.Count(pattern, "*") > 1 {
+        return fmt.Errorf("invalid logger name pattern %q", pattern)
+    }
+    return nil
+}
+
+// ParseLevelSpec parses comma-separated pattern=level pairs such as
+// "storage.*=DEBUG,http=warn", e.g. for Config.ComponentLevels
+func ParseLevelSpec(spec string) (map[string]LogLevel, error) {
+    levels := make(map[string]LogLevel)
+    for _, pair := range strings.Split(spec, ",") {
+        if strings.TrimSpace(pair) == "" {
+            continue
+        }
+        i := strings.IndexByte(pair, '=')
+        if i < 0 {
+            return nil, fmt.Errorf("invalid level spec %q, expected pattern=level", pair)
+        }
+        pattern := strings.TrimSpace(pair[:i])
+        if err := validatePattern(pattern); err != nil {
+            return nil, err
+        }
+        level, err := ParseLevel(pair[i+1:])
+        if err != nil {
+            return nil, err
+        }
+        levels[pattern] = level
+    }
+    return levels, nil
+}
@@ -0,0 +1,162 @@
+package logr
+
+import (
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestNamedLoggerLevels(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_levels"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:          tempDir,
+        FileName:        "levels",
+        MaxSize:         1024 * 1024,
+        MaxAge:          time.Hour,
+        Level:           INFO,
+        ComponentLevels: map[string]LogLevel{"storage.*": DEBUG, "storage.wal.sync": ERROR},
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    storage := logger.Named("storage")
+    wal := storage.With(String("shard", "3")).Named("wal")
+    walSync := wal.Named("sync")
+    http := logger.Named("http")
+    if wal.Name() != "storage.wal" || walSync.Name() != "storage.wal.sync" {
+        t.Errorf("unexpected names %q and %q", wal.Name(), walSync.Name())
+    }
+
+    checks := []struct {
+        logger   *Logger
+        expected LogLevel
+    }{
+        {logger, INFO},
+        {storage, INFO}, // "storage.*" covers descendants only
+        {wal, DEBUG},
+        {walSync, ERROR}, // An exact name takes precedence over patterns
+        {http, INFO},
+    }
+    for _, check := range checks {
+        if got := check.logger.GetLevel(); got != check.expected {
+            t.Errorf("expected %q to be at %v, got %v", check.logger.Name(), check.expected, got)
+        }
+    }
+
+    wal.Debug("segment %d written", 7)
+    storage.Debug("dropped")
+    walSync.Warn("dropped")
+
+    // Longer patterns win, and the root level applies to names without one
+    logger.SetComponentLevel("storage.wal.*", WARN)
+    logger.SetLevel(DEBUG)
+    if walSync.GetLevel() != ERROR || wal.GetLevel() != DEBUG || walSync.Named("fsync").GetLevel() != WARN || http.GetLevel() != DEBUG {
+        t.Errorf("unexpected levels after changes: %v", logger.ComponentLevels())
+    }
+    http.Debug("request served")
+
+    // SetLevel on a named logger sets the level of its name
+    http.SetLevel(WARN)
+    logger.ResetComponentLevel("storage.wal.sync")
+    if http.GetLevel() != WARN || logger.GetLevel() != DEBUG || walSync.GetLevel() != WARN {
+        t.Errorf("unexpected levels after SetLevel: %v", logger.ComponentLevels())
+    }
+    http.Info("dropped")
+    logger.Close()
+
+    data, err := os.ReadFile(filepath.Join(tempDir, "levels.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+    expected := []string{
+        "[DEBUG] segment 7 written logger=storage.wal shard=3",
+        "[DEBUG] request served logger=http",
+    }
+    if len(lines) != len(expected) {
+        t.Fatalf("expected %d lines, got %d: %q", len(expected), len(lines), lines)
+    }
+    for i, line := range lines {
+        if !strings.HasSuffix(line, expected[i]) {
+            t.Errorf("expected line %d to end with %q, got %q", i, expected[i], line)
+        }
+    }
+    if names := logger.Components(); strings.Join(names, ",") != "http,storage,storage.wal,storage.wal.sync,storage.wal.sync.fsync" {
+        t.Errorf("unexpected components %v", names)
+    }
+}
+
+func TestParseLevelSpec(t *testing.T) {
+    levels, err := ParseLevelSpec("storage.*=DEBUG, http=warn,,*=error")
+    if err != nil {
+        t.Fatalf("failed to parse spec: %v", err)
+    }
+    if len(levels) != 3 || levels["storage.*"] != DEBUG || levels["http"] != WARN || levels["*"] != ERROR {
+        t.Errorf("unexpected levels %v", levels)
+    }
+    for _, spec := range []string{"storage", "storage*=DEBUG", "a.*.b=INFO", "http=LOUD", "=INFO"} {
+        if _, err := ParseLevelSpec(spec); err == nil {
+            t.Errorf("expected an error for %q", spec)
+        }
+    }
+    if _, err := NewLogger(&Config{LogDir: "./test_logs_spec", ComponentLevels: map[string]LogLevel{"a*": DEBUG}}); err == nil {
+        t.Error("expected an invalid pattern to be rejected")
+    }
+    os.RemoveAll("./test_logs_spec")
+}
+
+func TestLevelChangesConcurrent(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_levels_concurrent"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := NewLogger(&Config{LogDir: tempDir, FileName: "concurrent", MaxSize: 1024 * 1024, Level: INFO})
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    var wg sync.WaitGroup
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            db := logger.Named("db")
+            for j := 0; j < 1000; j++ {
+                db.Debug("query %d", j)
+            }
+        }()
+    }
+    for j := 0; j < 100; j++ {
+        logger.SetComponentLevel("db", LogLevel(j%2))
+    }
+    wg.Wait()
+}
+
+func BenchmarkDiscardedDebug(b *testing.B) {
+    // Create temporary directory
+    tempDir := "./bench_logs_discard"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := NewLogger(&Config{LogDir: tempDir, FileName: "bench", MaxSize: 100 * 1024 * 1024, Level: INFO})
+    if err != nil {
+        b.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+    wal := logger.Named("storage.wal")
+
+    b.ResetTimer()
+    b.RunParallel(func(pb *testing.PB) {
+        for pb.Next() {
+            wal.Debug("segment %d written", 7)
+        }
+    })
+}
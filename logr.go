@@ -1,14 +1,12 @@
 package logr
 
 import (
-    "compress/gzip"
     "fmt"
-    "io"
     "os"
     "path/filepath"
-    "sort"
     "strings"
     "sync"
+    "sync/atomic"
     "time"
 )
 
@@ -41,6 +39,27 @@ func (l LogLevel) String() string {
     }
 }
 
+// lowerString returns the lowercase name of the log level
+func (l LogLevel) lowerString() string {
+    return strings.ToLower(l.String())
+}
+
+// MarshalText implements encoding.TextMarshaler, so levels are written as
+// their names in JSON
+func (l LogLevel) MarshalText() ([]byte, error) {
+    return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using ParseLevel
+func (l *LogLevel) UnmarshalText(text []byte) error {
+    level, err := ParseLevel(string(text))
+    if err != nil {
+        return err
+    }
+    *l = level
+    return nil
+}
+
 // Config represents the logger configuration
 type Config struct {
     LogDir       string        // Log directory
@@ -48,10 +67,42 @@ type Config struct {
     MaxSize      int64         // Maximum size of a single log file (bytes)
     MaxAge       time.Duration // Log file retention time
     MaxBackups   int           // Maximum number of backup files
+    MaxTotalSize int64         // Maximum total size of the active file (counted at MaxSize) and backups (bytes, 0 means no limit)
     Level        LogLevel      // Log level
     EnableStdout bool          // Whether to output to stdout simultaneously
     SyncInterval time.Duration // Interval for periodic sync (0 means no periodic sync)
     Compress     bool          // Whether to compress rotated log files with gzip
+    Encoder      Encoder       // Record encoder (nil means the text encoder)
+
+    BackupNameTemplate string // Backup file naming template (empty means DefaultBackupNameTemplate)
+    CompressWorkers    int    // Maximum number of concurrent background compressions (0 means 1)
+
+    OnRecovery func(RecoveryEvent) // Called for each repair made to LogDir on startup
+
+    DiskLowWatermark  int64           // Free bytes on LogDir's filesystem below which backups are pruned and output degrades (0 disables)
+    DiskHighWatermark int64           // Free bytes required to leave degraded mode (0 means DiskLowWatermark)
+    DiskCheckInterval time.Duration   // Interval between free space checks (0 means 10s)
+    DegradedLevel     LogLevel        // Minimum level written to the file while degraded (DEBUG means ERROR)
+    FallbackSink      Sink            // If set, receives file-bound records while degraded instead of the file
+    OnDiskState       func(DiskEvent) // Called when the logger enters or leaves degraded mode
+
+    Async          bool           // Whether to write records through a bounded queue drained by a writer goroutine
+    QueueSize      int            // Capacity of the async queue (0 means 4096)
+    OverflowPolicy OverflowPolicy // What to do when the async queue is full
+    OverflowLevel  LogLevel       // Records below this level are dropped on overflow with OverflowDropBelowLevel
+
+    RotateInterval time.Duration  // Rotate at clock boundaries of this interval, e.g. time.Hour or 24*time.Hour (0 disables)
+    RotateLocation *time.Location // Time zone used for rotation boundaries (nil means local time)
+
+    HashChain   bool     // Whether to chain file records with SHA-256 hashes for tamper evidence (see Verify)
+    SigningKeys *Keyring // If set, each finished backup gets a detached .sig manifest signed with the active key
+
+    EncryptionKeys KeyProvider // If set, backups are encrypted with AES-GCM after compression, and readers decrypt with it
+    EncryptActive  bool        // Whether to also encrypt the active file in segments sealed on every sync (requires EncryptionKeys)
+
+    Redaction *Redaction // If set, sensitive data in messages and fields is masked before records are encoded
+
+    ComponentLevels map[string]LogLevel // Levels of named loggers by name or pattern such as "storage.*" (see SetComponentLevel)
 }
 
 // DefaultConfig returns the default configuration
@@ -66,17 +117,88 @@ func DefaultConfig() *Config {
         EnableStdout: false,
         SyncInterval: 100 * time.Millisecond, // 100ms periodic sync by default
         Compress:     true,                   // Compression by default
+        Encoder:      NewTextEncoder(),
     }
 }
 
 // Logger represents the logger instance
 type Logger struct {
+    *loggerState
+
+    fields []Field // Fields prepended to every record, starting with the name field of named loggers
+    name   string  // Name given by Named, empty for the root logger
+    level  *int32  // Effective level, the root level or that of the logger's name; accessed atomically
+}
+
+// Stats holds logger counters
+type Stats struct {
+    Dropped          uint64 // Records dropped because the async queue was full, they were logged after Close, or sealing them to the encrypted active file failed
+    Queued           int    // Records currently waiting in the async queue
+    DiskDegraded     bool   // Whether output is degraded because of low disk space
+    DiskDropped      uint64 // Records not written to the file because of low disk space
+    DiskStateChanges uint64 // Number of transitions into and out of degraded mode
+    Redactions       uint64 // Sensitive values masked by the redaction stage
+}
+
+// loggerState holds the output state shared by a logger and its children
+type loggerState struct {
+    // Accessed atomically, kept first for 64-bit alignment
+    dropped          uint64
+    diskDropped      uint64
+    diskStateChanges uint64
+    redactions       uint64
+    degraded         int32
+    rootLevel        int32
+
     config      *Config
+    encoder     Encoder
+    redactor    *redactor // nil unless redaction is enabled
+    sinks       []namedSink
     file        *os.File
     currentSize int64
+    periodEnd   time.Time // End of the active file's rotation period (zero when time rotation is disabled)
+    namer       *backupNamer
+    nextSeq     uint64 // Sequence number of the next backup
     mu          sync.Mutex
     syncTicker  *time.Ticker
     stopChan    chan struct{}
+    cleanupWake chan struct{} // Requests a retention pass from the cleanup goroutine
+    closeOnce   sync.Once
+    closed      bool // Set once the sinks are closed; guarded by mu
+
+    // Level state; levelMu guards changes, while effective levels are read atomically
+    levelMu       sync.Mutex
+    levelPatterns map[string]LogLevel // Levels set by name or pattern
+    components    map[string]*int32   // Effective levels of the named loggers by name
+
+    // Background compression state
+    compressMu      sync.Mutex
+    compressCond    *sync.Cond    // Signals pending jobs, nil unless the workers are started
+    compressPending []compressJob // Jobs waiting for a worker
+    compressClosed  bool
+    compressWG      sync.WaitGroup
+
+    // Signing journal state
+    journalMu sync.Mutex
+    unsigned  map[string]string // Content hashes of backups waiting to be signed, nil unless signing is enabled
+
+    // Disk guard state
+    diskCheck chan struct{}
+
+    // Hash chain state
+    chainHash []byte // Hash of the active file's last record, nil until its chain starts
+    chainPrev []byte // Final hash of the previous file, linked from the next chain start
+
+    // Active file encryption state
+    segments       *segmentCipher // Cipher of the active file, nil unless EncryptActive
+    segmentBuf     []byte         // Records not sealed yet
+    segmentRecords int            // Number of records in segmentBuf
+    segmentsBroken bool           // A failed write used up segment nonces; the file must be rotated
+
+    // Async mode state
+    queue     chan *Entry
+    flushChan chan chan error
+    asyncDone chan struct{}
 }
 
 // NewLogger creates a new logger instance
@@ -90,9 +212,64 @@ func NewLogger(config *Config) (*Logger, error) {
         return nil, fmt.Errorf("failed to create log directory: %v", err)
     }
 
-    logger := &Logger{
-        config:   config,
-        stopChan: make(chan struct{}),
+    namer, err := newBackupNamer(config.FileName, config.BackupNameTemplate)
+    if err != nil {
+        return nil, err
+    }
+    if config.SigningKeys != nil {
+        if err := config.SigningKeys.validate(); err != nil {
+            return nil, err
+        }
+    }
+    if config.EncryptActive && config.EncryptionKeys == nil {
+        return nil, errEncryptActiveWithoutKeys
+    }
+    if config.EncryptionKeys != nil {
+        if _, err := newSegmentCipher(config.EncryptionKeys); err != nil {
+            return nil, err
+        }
+    }
+
+    state := &loggerState{
+        rootLevel:     int32(config.Level),
+        config:        config,
+        encoder:       config.Encoder,
+        namer:         namer,
+        stopChan:      make(chan struct{}),
+        cleanupWake:   make(chan struct{}, 1),
+        levelPatterns: make(map[string]LogLevel),
+        components:    make(map[string]*int32),
+    }
+    for pattern, level := range config.ComponentLevels {
+        if err := validatePattern(pattern); err != nil {
+            return nil, err
+        }
+        state.levelPatterns[pattern] = level
+    }
+    logger := &Logger{loggerState: state, level: &state.rootLevel}
+    if logger.encoder == nil {
+        logger.encoder = NewTextEncoder()
+    }
+    if config.Redaction != nil {
+        if logger.redactor, err = newRedactor(config.Redaction, &logger.redactions); err != nil {
+            return nil, err
+        }
+    }
+
+    if config.SigningKeys != nil {
+        logger.loadJournal()
+    }
+
+    // Continue the backup sequence after the newest existing backup
+    files, err := logger.getLogFiles()
+    if err != nil {
+        return nil, fmt.Errorf("failed to list log files: %v", err)
+    }
+    logger.nextSeq = 1
+    for _, file := range files {
+        if file.seq >= logger.nextSeq {
+            logger.nextSeq = file.seq + 1
+        }
     }
 
     // Open or create log file
@@ -100,9 +277,61 @@ func NewLogger(config *Config) (*Logger, error) {
         return nil, err
     }
 
+    // Start a new file rather than mix encrypted and plain records
+    if logger.activeFormatChanged() {
+        if !config.EncryptActive && config.EncryptionKeys != nil {
+            if err := finishEncryptedFile(logger.getCurrentLogPath(), config.EncryptionKeys); err != nil {
+                fmt.Fprintf(diagnostics, "Warning: failed to finish encrypted log file: %v\n", err)
+            }
+        }
+        if err := logger.rotateFile(); err != nil {
+            logger.file.Close()
+            return nil, err
+        }
+    }
+
+    // Continue the hash chain where the log files end
+    if config.HashChain {
+        if err := logger.initChain(); err != nil {
+            logger.file.Close()
+            return nil, err
+        }
+    }
+
+    // Register the rotating file and stdout as sinks
+    logger.sinks = append(logger.sinks, namedSink{name: FileSinkName, sink: &fileSink{l: logger}})
+    if config.EnableStdout {
+        logger.sinks = append(logger.sinks, namedSink{name: StdoutSinkName, sink: NewWriterSink(os.Stdout, logger.encoder, DEBUG)})
+    }
+
+    // Start background compression, encryption and signing workers if enabled
+    if config.Compress || config.SigningKeys != nil || config.EncryptionKeys != nil {
+        logger.startCompressors()
+    }
+
+    // Repair rotations and compressions interrupted by a crash
+    if err := logger.recoverLogDir(); err != nil {
+        fmt.Fprintf(diagnostics, "Warning: log directory recovery incomplete: %v\n", err)
+    }
+
+    // Start async writer goroutine if enabled
+    if config.Async {
+        logger.startAsync()
+    }
+
     // Start cleanup goroutine
     go logger.cleanupRoutine()
 
+    // Start free disk space guard if enabled
+    if config.DiskLowWatermark > 0 {
+        logger.startDiskGuard()
+    }
+
+    // Start time-based rotation goroutine if enabled
+    if config.RotateInterval > 0 {
+        go logger.rotateRoutine()
+    }
+
     // Start periodic sync goroutine if enabled
     if config.SyncInterval > 0 {
         logger.syncTicker = time.NewTicker(config.SyncInterval)
@@ -117,11 +346,24 @@ func (l *Logger) openLogFile() error {
     logPath := l.getCurrentLogPath()
 
     // Check if file exists, get current size if it does
+    now := time.Now()
     if info, err := os.Stat(logPath); err == nil {
         l.currentSize = info.Size()
+        // A file left over from an earlier period belongs to that period
+        if l.currentSize > 0 && l.config.RotateInterval > 0 {
+            now = info.ModTime()
+        }
     } else {
         l.currentSize = 0
     }
+    l.periodEnd = l.nextRotationTime(now)
+
+    // Continue or start the sealed segments of an encrypted active file
+    if l.config.EncryptActive {
+        if err := l.openSegments(logPath); err != nil {
+            return err
+        }
+    }
 
     // Open file in append mode
     file, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
@@ -144,53 +386,29 @@ func (l *Logger) getCurrentLogPath() string {
 }
 
 // getBackupLogPath gets the backup log file path
-func (l *Logger) getBackupLogPath(timestamp time.Time) string {
-    timeStr := timestamp.Format("20060102_150405")
+func (l *Logger) getBackupLogPath(timestamp time.Time, seq uint64) string {
+    path := filepath.Join(l.config.LogDir, l.namer.format(timestamp, seq))
     if l.config.Compress {
-        return filepath.Join(l.config.LogDir, fmt.Sprintf("%s_%s.log.gz", l.config.FileName, timeStr))
+        return path + ".gz"
     }
-    return filepath.Join(l.config.LogDir, fmt.Sprintf("%s_%s.log", l.config.FileName, timeStr))
-}
-
-// compressFile compresses a log file to gzip format
-func (l *Logger) compressFile(srcPath, dstPath string) error {
-    // Open source file
-    srcFile, err := os.Open(srcPath)
-    if err != nil {
-        return fmt.Errorf("failed to open source file: %v", err)
-    }
-    defer srcFile.Close()
-
-    // Create destination gzip file
-    dstFile, err := os.Create(dstPath)
-    if err != nil {
-        return fmt.Errorf("failed to create gzip file: %v", err)
-    }
-    defer dstFile.Close()
-
-    // Create gzip writer
-    gzipWriter := gzip.NewWriter(dstFile)
-    defer gzipWriter.Close()
-
-    // Copy file content to gzip
-    _, err = io.Copy(gzipWriter, srcFile)
-    if err != nil {
-        return fmt.Errorf("failed to copy file content: %v", err)
-    }
-
-    return nil
+    return path
 }
 
 // rotateFile rotates the log file with proper synchronization
 func (l *Logger) rotateFile() error {
     // Store old file reference
     oldFile := l.file
+    if err := l.sealSegments(); err != nil {
+        fmt.Fprintf(diagnostics, "Warning: failed to seal log file during rotation: %v\n", err)
+    } else if err := l.finishSegments(); err != nil {
+        fmt.Fprintf(diagnostics, "Warning: failed to finish log file during rotation: %v\n", err)
+    }
 
     // Sync and close current file safely
     if oldFile != nil {
         if err := oldFile.Sync(); err != nil {
             // Log sync error but continue with rotation
-            fmt.Fprintf(os.Stderr, "Warning: failed to sync log file during rotation: %v\n", err)
+            fmt.Fprintf(diagnostics, "Warning: failed to sync log file during rotation: %v\n", err)
         }
         oldFile.Close()
         l.file = nil // Clear reference immediately
@@ -199,28 +417,20 @@ func (l *Logger) rotateFile() error {
     currentPath := l.getCurrentLogPath()
     timestamp := time.Now()
 
+    // Rename current file to backup file; compression happens off the write path
+    backupPath := l.nextBackupPath(timestamp)
+    renamedPath := strings.TrimSuffix(backupPath, ".gz")
+    l.journalBackup(renamedPath, currentPath) // Before a crash can leave it unsigned
+    if err := os.Rename(currentPath, renamedPath); err != nil {
+        // Try to reopen the original file if rename fails
+        l.unjournalBackup(renamedPath)
+        l.openLogFile()
+        return fmt.Errorf("failed to rename log file: %v", err)
+    }
+    l.resetChain()
     if l.config.Compress {
-        // Compress the current log file
-        backupPath := l.getBackupLogPath(timestamp)
-        if err := l.compressFile(currentPath, backupPath); err != nil {
-            // Try to reopen the original file if compression fails
-            l.openLogFile()
-            return fmt.Errorf("failed to compress log file: %v", err)
-        }
-
-        // Remove the original uncompressed file
-        if err := os.Remove(currentPath); err != nil {
-            // Log warning but don't fail rotation
-            fmt.Fprintf(os.Stderr, "Warning: failed to remove original log file: %v\n", err)
-        }
-    } else {
-        // Rename current file to backup file (original behavior)
-        backupPath := l.getBackupLogPath(timestamp)
-        if err := os.Rename(currentPath, backupPath); err != nil {
-            // Try to reopen the original file if rename fails
-            l.openLogFile()
-            return fmt.Errorf("failed to rename log file: %v", err)
-        }
+        // Retention is applied once the archive's compressed size is known
+        l.enqueueCompression(renamedPath, backupPath)
     }
 
     // Reset current size
@@ -231,6 +441,12 @@ func (l *Logger) rotateFile() error {
         return fmt.Errorf("failed to open new log file after rotation: %v", err)
     }
 
+    if !l.config.Compress {
+        if l.config.SigningKeys != nil || l.config.EncryptionKeys != nil {
+            l.enqueueBackup(renamedPath)
+        }
+        l.cleanupLocked()
+    }
     return nil
 }
 
@@ -240,90 +456,246 @@ func (l *Logger) shouldRotate(messageSize int) bool {
 }
 
 // writeLog writes a log message
-func (l *Logger) writeLog(level LogLevel, message string) {
-    if level < l.config.Level {
+func (l *Logger) writeLog(level LogLevel, message string, fields []Field) {
+    if level < LogLevel(atomic.LoadInt32(l.level)) {
+        return
+    }
+
+    l.writeEntry(&Entry{
+        Level:   level,
+        Message: message,
+        Fields:  l.mergeFields(fields),
+    }, true)
+}
+
+// writeEntry masks an entry and writes it to the sinks, through the queue in
+// async mode. If stamp is set, the entry's time is set to the time of writing.
+func (l *Logger) writeEntry(entry *Entry, stamp bool) {
+    // Mask sensitive data before any sink encodes the record
+    if l.redactor != nil {
+        l.redactor.redactEntry(entry)
+    }
+
+    if l.queue != nil {
+        if stamp {
+            entry.Time = time.Now()
+        }
+        l.enqueue(entry)
         return
     }
 
     l.mu.Lock()
     defer l.mu.Unlock()
 
-    // Format log message
-    timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-    logMessage := fmt.Sprintf("[%s] [%s] %s\n", timestamp, level.String(), message)
+    // Nothing writes the sinks once they are closed, as in async mode
+    if l.closed {
+        atomic.AddUint64(&l.dropped, 1)
+        return
+    }
+    if stamp {
+        entry.Time = time.Now()
+    }
+    l.writeSinks(entry)
+}
+
+// writeFile encodes an entry and writes it to the active log file
+func (l *Logger) writeFile(entry *Entry) error {
+    return l.writeFileBatch([]*Entry{entry})
+}
+
+// writeFileBatch encodes entries and writes them to the active log file
+// with as few write calls as rotation allows
+func (l *Logger) writeFileBatch(entries []*Entry) error {
+    // The file is nil once the sink has been closed
+    if l.file == nil {
+        return nil
+    }
 
-    // Check if rotation is needed
-    if l.shouldRotate(len(logMessage)) {
+    // Sealing cannot continue in a file whose segment nonces a failed write
+    // used up; continue in a new file with a new salt instead
+    if l.segmentsBroken {
         if err := l.rotateFile(); err != nil {
-            fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
-            return
+            return fmt.Errorf("log rotation failed: %v", err)
         }
     }
 
-    // Write to file
-    if l.file != nil {
-        n, err := l.file.WriteString(logMessage)
+    var buf []byte
+    var records int
+    var firstErr error
+    for _, entry := range entries {
+        // Format log message
+        logMessage, err := l.encoder.Encode(entry)
         if err != nil {
-            fmt.Fprintf(os.Stderr, "failed to write to log file: %v\n", err)
-            return
+            if firstErr == nil {
+                firstErr = fmt.Errorf("failed to encode log message: %v", err)
+            }
+            continue
         }
-        l.currentSize += int64(n)
 
-        // Note: Removed forced sync for better performance
-        // Sync will be called during rotation and close operations
+        // An empty file simply carries over into the new period
+        if l.periodExpired(entry.Time) && l.currentSize+int64(len(buf)) == 0 {
+            l.periodEnd = l.nextRotationTime(entry.Time)
+        }
+
+        // Check if rotation is needed
+        if l.shouldRotate(len(buf)+len(logMessage)+l.chainOverhead()) || l.periodExpired(entry.Time) {
+            if err := l.flushFileBuffer(buf, records); err != nil {
+                return err
+            }
+            buf, records = buf[:0], 0
+            if err := l.rotateFile(); err != nil {
+                return fmt.Errorf("log rotation failed: %v", err)
+            }
+        }
+
+        // Link the record to the active file's hash chain
+        if l.config.HashChain {
+            if logMessage, err = l.chainRecord(logMessage, entry.Time); err != nil {
+                if firstErr == nil {
+                    firstErr = fmt.Errorf("failed to chain log message: %v", err)
+                }
+                continue
+            }
+        }
+        buf = append(buf, logMessage...)
+        records++
     }
 
-    // Also output to stdout
-    if l.config.EnableStdout {
-        fmt.Print(logMessage)
+    if err := l.flushFileBuffer(buf, records); err != nil {
+        return err
     }
+    return firstErr
+}
+
+// flushFileBuffer writes buffered log lines, holding the given number of
+// records, to the active log file
+func (l *Logger) flushFileBuffer(buf []byte, records int) error {
+    if len(buf) == 0 || l.file == nil {
+        return nil
+    }
+    if l.segments != nil {
+        // Encrypted records are written when a segment fills up or is sealed by a sync
+        l.segmentBuf = append(l.segmentBuf, buf...)
+        l.segmentRecords += records
+        l.currentSize += int64(len(buf))
+        if len(l.segmentBuf) >= encryptSegmentSize {
+            return l.sealSegments()
+        }
+        return nil
+    }
+
+    n, err := l.file.Write(buf)
+    l.currentSize += int64(n)
+    if err != nil {
+        // A full disk is the usual cause; let the disk guard react right away
+        l.requestDiskCheck()
+        return fmt.Errorf("failed to write to log file: %v", err)
+    }
+
+    // Note: Removed forced sync for better performance
+    // Sync will be called during rotation and close operations
+    return nil
+}
+
+// mergeFields returns the logger's fields followed by fields
+func (l *Logger) mergeFields(fields []Field) []Field {
+    if len(l.fields) == 0 {
+        return fields
+    }
+    if len(fields) == 0 {
+        return l.fields
+    }
+    merged := make([]Field, 0, len(l.fields)+len(fields))
+    merged = append(merged, l.fields...)
+    return append(merged, fields...)
+}
+
+// logf formats and writes a message, skipping the formatting when the level
+// is disabled
+func (l *Logger) logf(level LogLevel, format string, args []interface{}) {
+    if !l.Enabled(level) {
+        return
+    }
+    l.writeLog(level, fmt.Sprintf(format, args...), nil)
 }
 
 // Debug logs a debug message
 func (l *Logger) Debug(format string, args ...interface{}) {
-    message := fmt.Sprintf(format, args...)
-    l.writeLog(DEBUG, message)
+    l.logf(DEBUG, format, args)
 }
 
 // Info logs an info message
 func (l *Logger) Info(format string, args ...interface{}) {
-    message := fmt.Sprintf(format, args...)
-    l.writeLog(INFO, message)
+    l.logf(INFO, format, args)
 }
 
 // Warn logs a warning message
 func (l *Logger) Warn(format string, args ...interface{}) {
-    message := fmt.Sprintf(format, args...)
-    l.writeLog(WARN, message)
+    l.logf(WARN, format, args)
 }
 
 // Error logs an error message
 func (l *Logger) Error(format string, args ...interface{}) {
-    message := fmt.Sprintf(format, args...)
-    l.writeLog(ERROR, message)
+    l.logf(ERROR, format, args)
 }
 
 // Fatal logs a fatal error message and exits the program
 func (l *Logger) Fatal(format string, args ...interface{}) {
     message := fmt.Sprintf(format, args...)
-    l.writeLog(FATAL, message)
+    l.writeLog(FATAL, message, nil)
 
     // Ensure fatal log is written to disk before exiting
     // Use a separate function to avoid potential deadlock
     l.syncAndExit()
 }
 
+// Debugw logs a debug message with structured fields
+func (l *Logger) Debugw(msg string, fields ...Field) {
+    l.writeLog(DEBUG, msg, fields)
+}
+
+// Infow logs an info message with structured fields
+func (l *Logger) Infow(msg string, fields ...Field) {
+    l.writeLog(INFO, msg, fields)
+}
+
+// Warnw logs a warning message with structured fields
+func (l *Logger) Warnw(msg string, fields ...Field) {
+    l.writeLog(WARN, msg, fields)
+}
+
+// Errorw logs an error message with structured fields
+func (l *Logger) Errorw(msg string, fields ...Field) {
+    l.writeLog(ERROR, msg, fields)
+}
+
+// Fatalw logs a fatal error message with structured fields and exits the program
+func (l *Logger) Fatalw(msg string, fields ...Field) {
+    l.writeLog(FATAL, msg, fields)
+    l.syncAndExit()
+}
+
+// With returns a child logger that prepends fields to every record.
+// The child shares the parent's file, rotation state and lock.
+func (l *Logger) With(fields ...Field) *Logger {
+    if len(fields) == 0 {
+        return l
+    }
+    return &Logger{
+        loggerState: l.loggerState,
+        fields:      l.mergeFields(fields),
+        name:        l.name,
+        level:       l.level,
+    }
+}
+
 // syncAndExit safely syncs the log file and exits
 func (l *Logger) syncAndExit() {
     // Try to acquire lock with timeout to prevent hanging
     done := make(chan struct{})
     go func() {
         defer close(done)
-        l.mu.Lock()
-        defer l.mu.Unlock()
-        if l.file != nil {
-            l.file.Sync()
-        }
+        l.Sync()
     }()
 
     // Wait for sync with timeout
@@ -332,7 +704,7 @@ func (l *Logger) syncAndExit() {
         // Sync completed successfully
     case <-time.After(5 * time.Second):
         // Timeout - force exit to prevent hanging
-        fmt.Fprintf(os.Stderr, "Warning: Log sync timed out during fatal exit\n")
+        fmt.Fprintf(diagnostics, "Warning: Log sync timed out during fatal exit\n")
     }
 
     os.Exit(1)
@@ -347,6 +719,10 @@ func (l *Logger) cleanupRoutine() {
         select {
         case <-ticker.C:
             l.cleanup()
+        case <-l.cleanupWake:
+            l.mu.Lock()
+            l.cleanupLocked()
+            l.mu.Unlock()
         case <-l.stopChan:
             return
         }
@@ -362,6 +738,7 @@ func (l *Logger) syncRoutine() {
         case <-l.syncTicker.C:
             l.mu.Lock()
             if l.file != nil {
+                l.sealSegments()
                 l.file.Sync()
             }
             l.mu.Unlock()
@@ -371,36 +748,56 @@ func (l *Logger) syncRoutine() {
     }
 }
 
+// requestCleanup asks the cleanup goroutine for a retention pass without
+// waiting for it; requests made while one is pending are merged
+func (l *Logger) requestCleanup() {
+    select {
+    case l.cleanupWake <- struct{}{}:
+    default:
+    }
+}
+
 // cleanup removes expired log files
 func (l *Logger) cleanup() {
     l.mu.Lock()
-    defer l.mu.Unlock()
+    deleted := l.cleanupLocked()
+    l.mu.Unlock()
+
+    if deleted > 0 {
+        fmt.Printf("cleaned up %d expired log files\n", deleted)
+    }
+}
 
+// cleanupLocked applies the retention rules and returns the number of deleted
+// backups; the caller must hold l.mu
+func (l *Logger) cleanupLocked() int {
     // Get all log files
     files, err := l.getLogFiles()
     if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to get log file list: %v\n", err)
-        return
+        fmt.Fprintf(diagnostics, "failed to get log file list: %v\n", err)
+        return 0
     }
 
     now := time.Now()
     deleted := 0
 
-    // Sort by time, newest first
-    sort.Slice(files, func(i, j int) bool {
-        return files[i].ModTime().After(files[j].ModTime())
-    })
-
-    for i, file := range files {
-        // Skip the currently active log file
-        if file.Name() == l.config.FileName+".log" {
+    // Walk backups newest first, skipping the currently active log file
+    var totalSize int64
+    backups := make([]logFile, 0, len(files))
+    for i := len(files) - 1; i >= 0; i-- {
+        if files[i].active {
+            totalSize += l.activeSize(files[i])
             continue
         }
+        backups = append(backups, files[i])
+    }
 
+    var kept []logFile
+    for i, file := range backups {
         shouldDelete := false
 
         // Check if it exceeds retention time
-        if l.config.MaxAge > 0 && now.Sub(file.ModTime()) > l.config.MaxAge {
+        if l.config.MaxAge > 0 && now.Sub(file.modTime) > l.config.MaxAge {
             shouldDelete = true
         }
 
@@ -409,95 +806,133 @@ func (l *Logger) cleanup() {
             shouldDelete = true
         }
 
-        if shouldDelete {
-            filePath := filepath.Join(l.config.LogDir, file.Name())
-            if err := os.Remove(filePath); err != nil {
-                fmt.Fprintf(os.Stderr, "failed to delete expired log file %s: %v\n", filePath, err)
-            } else {
-                deleted++
-            }
+        if !shouldDelete {
+            kept = append(kept, file)
+            totalSize += file.size
+            continue
         }
-    }
-
-    if deleted > 0 {
-        fmt.Printf("cleaned up %d expired log files\n", deleted)
-    }
-}
-
-// getLogFiles gets all log files
-func (l *Logger) getLogFiles() ([]os.FileInfo, error) {
-    files, err := os.ReadDir(l.config.LogDir)
-    if err != nil {
-        return nil, err
-    }
-
-    var logFiles []os.FileInfo
-    prefix := l.config.FileName
-
-    for _, file := range files {
-        if file.IsDir() {
+        if err := removeBackup(file.path); os.IsNotExist(err) {
+            // A compressor replaced it after the listing; the next pass sees the archive
             continue
+        } else if err != nil {
+            fmt.Fprintf(diagnostics, "failed to delete expired log file %s: %v\n", file.path, err)
+            totalSize += file.size
+        } else {
+            deleted++
         }
+    }
 
-        name := file.Name()
-        // Match current log file or backup log files (both .log and .log.gz)
-        if name == prefix+".log" ||
-            (strings.HasPrefix(name, prefix+"_") && strings.HasSuffix(name, ".log")) ||
-            (strings.HasPrefix(name, prefix+"_") && strings.HasSuffix(name, ".log.gz")) {
-            info, err := file.Info()
-            if err != nil {
+    // Delete the oldest remaining backups until the directory fits the budget
+    if l.config.MaxTotalSize > 0 {
+        for i := len(kept) - 1; i >= 0 && totalSize > l.config.MaxTotalSize; i-- {
+            if err := removeBackup(kept[i].path); os.IsNotExist(err) {
+                totalSize -= kept[i].size
+                continue
+            } else if err != nil {
+                fmt.Fprintf(diagnostics, "failed to delete log file %s over size budget: %v\n", kept[i].path, err)
                 continue
             }
-            logFiles = append(logFiles, info)
+            totalSize -= kept[i].size
+            deleted++
         }
     }
 
-    return logFiles, nil
+    return deleted
+}
+
+// activeSize returns the space reserved for the active log file: the size it may
+// grow to before rotating, so that the total size budget holds while it is written
+func (l *Logger) activeSize(file logFile) int64 {
+    size := file.size
+    if l.currentSize > size {
+        size = l.currentSize
+    }
+    if l.config.MaxSize > size {
+        size = l.config.MaxSize
+    }
+    return size
 }
 
-// Close closes the logger
+// getLogFiles gets all log files in chronological order, the active file last
+func (l *Logger) getLogFiles() ([]logFile, error) {
+    return listLogFiles(l.config.LogDir, l.namer)
+}
+
+// Close closes the logger and all of its sinks; subsequent calls are no-ops
 func (l *Logger) Close() error {
-    // Stop background goroutines
-    close(l.stopChan)
+    var err error
+    l.closeOnce.Do(func() {
+        // Log what is left of captured standard error while the sinks are open
+        releaseStderrOwnedBy(l.loggerState)
 
-    l.mu.Lock()
-    defer l.mu.Unlock()
+        // Stop background goroutines
+        close(l.stopChan)
 
-    if l.file != nil {
-        // Sync before closing to ensure all data is written
-        l.file.Sync()
-        return l.file.Close()
-    }
-    return nil
+        // Wait for the async writer to drain the queue
+        if l.queue != nil {
+            <-l.asyncDone
+        }
+
+        l.mu.Lock()
+        l.closed = true
+        err = l.closeSinks()
+        l.stopCompressors()
+        l.mu.Unlock()
+
+        // Wait for in-flight compressions outside the lock, then apply the
+        // retention they requested after the cleanup goroutine stopped
+        if l.compressCond != nil {
+            l.waitCompressors(compressWaitTimeout)
+            l.mu.Lock()
+            l.cleanupLocked()
+            l.mu.Unlock()
+        }
+    })
+    return err
 }
 
-// SetLevel sets the log level
+// SetLevel sets the log level. On the root logger it sets the root level,
+// which named loggers without a level of their own follow; on a named logger
+// it sets the level of its name, like SetComponentLevel.
 func (l *Logger) SetLevel(level LogLevel) {
-    l.mu.Lock()
-    defer l.mu.Unlock()
-    l.config.Level = level
+    if l.name != "" {
+        l.SetComponentLevel(l.name, level)
+        return
+    }
+    l.setRootLevel(level)
 }
 
-// GetLevel gets the current log level
+// GetLevel gets the logger's effective log level without locking
 func (l *Logger) GetLevel() LogLevel {
-    l.mu.Lock()
-    defer l.mu.Unlock()
-    return l.config.Level
+    return LogLevel(atomic.LoadInt32(l.level))
+}
+
+// Enabled reports whether records at level pass the logger's level
+func (l *Logger) Enabled(level LogLevel) bool {
+    return level >= l.GetLevel()
 }
 
-// Sync forces a sync of the log file to disk
+// Sync forces a sync of all sinks, including the log file, to disk.
+// In async mode it first waits for queued records to be written.
 func (l *Logger) Sync() error {
+    if l.queue != nil {
+        return l.flushAsync()
+    }
+
     l.mu.Lock()
     defer l.mu.Unlock()
 
-    if l.file != nil {
-        return l.file.Sync()
-    }
-    return nil
+    return l.syncSinks()
 }
 
-// SetOutput sets additional output target
-func (l *Logger) SetOutput(w io.Writer) {
-    // This method can be used to add additional output targets, such as network log collectors
-    // Here provides a basic implementation framework
+// Stats returns a snapshot of the logger counters
+func (l *Logger) Stats() Stats {
+    return Stats{
+        Dropped:          atomic.LoadUint64(&l.dropped),
+        Queued:           len(l.queue),
+        DiskDegraded:     l.isDegraded(),
+        DiskDropped:      atomic.LoadUint64(&l.diskDropped),
+        DiskStateChanges: atomic.LoadUint64(&l.diskStateChanges),
+        Redactions:       atomic.LoadUint64(&l.redactions),
+    }
 }
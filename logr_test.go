@@ -1,6 +1,7 @@
 package logr
 
 import (
+    "errors"
     "os"
     "path/filepath"
     "strings"
@@ -62,6 +63,44 @@ func TestLoggerBasicFunctionality(t *testing.T) {
     }
 }
 
+func TestWriteAfterClose(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_closed"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := NewLogger(&Config{
+        LogDir:     tempDir,
+        FileName:   "closed",
+        MaxSize:    1024 * 1024,
+        MaxBackups: 3,
+        Level:      INFO,
+    })
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Info("before close")
+    if err := logger.Close(); err != nil {
+        t.Fatalf("failed to close logger: %v", err)
+    }
+
+    // Records logged after Close are counted as dropped, as in async mode
+    for i := 0; i < 10; i++ {
+        logger.Info("after close %d", i)
+    }
+    logger.Named("child").Warn("after close")
+    if dropped := logger.Stats().Dropped; dropped != 11 {
+        t.Errorf("expected 11 dropped records after close, got %d", dropped)
+    }
+
+    content, err := os.ReadFile(filepath.Join(tempDir, "closed.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    if !strings.Contains(string(content), "before close") || strings.Contains(string(content), "after close") {
+        t.Errorf("unexpected log file content: %q", content)
+    }
+}
+
 func TestLogRotation(t *testing.T) {
     // Create temporary directory
     tempDir := "./test_logs_rotation"
@@ -211,6 +250,68 @@ func TestLogCleanup(t *testing.T) {
     }
 }
 
+func TestLogCleanupTotalSize(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_total_size"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:       tempDir,
+        FileName:     "total_size_test",
+        MaxSize:      200,
+        MaxAge:       time.Hour,
+        MaxBackups:   100,
+        MaxTotalSize: 1000,
+        Level:        INFO,
+        EnableStdout: false,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    // Retention is evaluated right after each rotation, without waiting for the hourly tick
+    for i := 0; i < 100; i++ {
+        logger.Info("Log message %d - for testing the total size budget", i)
+    }
+    logger.Sync()
+
+    files, err := os.ReadDir(tempDir)
+    if err != nil {
+        t.Fatalf("failed to read directory: %v", err)
+    }
+
+    var total int64
+    backups := 0
+    for _, file := range files {
+        info, err := file.Info()
+        if err != nil {
+            t.Fatalf("failed to stat %s: %v", file.Name(), err)
+        }
+        total += info.Size()
+        if file.Name() != "total_size_test.log" {
+            backups++
+        }
+    }
+
+    if total > config.MaxTotalSize {
+        t.Errorf("expected at most %d bytes of logs, got %d", config.MaxTotalSize, total)
+    }
+    if backups == 0 {
+        t.Error("expected the newest backups to be kept")
+    }
+
+    content, err := os.ReadFile(filepath.Join(tempDir, "total_size_test.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    if !strings.Contains(string(content), "Log message 99") {
+        t.Error("expected the newest record in the active file")
+    }
+}
+
 func TestLogCompressionRotation(t *testing.T) {
     // Create temporary directory
     tempDir := "./test_logs_compression"
@@ -271,6 +372,53 @@ func TestLogCompressionRotation(t *testing.T) {
     t.Logf("Found %d log files and %d compressed files", logFileCount, gzFileCount)
 }
 
+func TestStructuredFields(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_fields"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:       tempDir,
+        FileName:     "fields_test",
+        MaxSize:      1024 * 1024,
+        MaxAge:       time.Hour,
+        MaxBackups:   3,
+        Level:        INFO,
+        EnableStdout: false,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    child := logger.With(String("user", "alice"), String("session", "s-1"))
+    child.Infow("query executed",
+        Int64("query_id", 42),
+        Duration("latency", 1500*time.Millisecond),
+        Error(errors.New("deadlock detected")),
+    )
+    logger.Infow("root record")
+
+    content, err := os.ReadFile(filepath.Join(tempDir, "fields_test.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+
+    lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+    if len(lines) != 2 {
+        t.Fatalf("expected 2 log lines, got %d", len(lines))
+    }
+    expected := `query executed user=alice session=s-1 query_id=42 latency=1.5s error="deadlock detected"`
+    if !strings.HasSuffix(lines[0], expected) {
+        t.Errorf("unexpected child record: %s", lines[0])
+    }
+    if strings.Contains(lines[1], "user=alice") {
+        t.Errorf("parent record should not carry child fields: %s", lines[1])
+    }
+}
+
 func BenchmarkLoggerWrite(b *testing.B) {
     // Create temporary directory
     tempDir := "./bench_logs"
@@ -299,3 +447,35 @@ func BenchmarkLoggerWrite(b *testing.B) {
         }
     })
 }
+
+func BenchmarkLoggerWriteAsync(b *testing.B) {
+    // Create temporary directory
+    tempDir := "./bench_logs_async"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:         tempDir,
+        FileName:       "bench",
+        MaxSize:        100 * 1024 * 1024, // 100MB
+        MaxAge:         time.Hour,
+        MaxBackups:     5,
+        Level:          INFO,
+        EnableStdout:   false,
+        Async:          true,
+        OverflowPolicy: OverflowBlock,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        b.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    b.ResetTimer()
+    b.RunParallel(func(pb *testing.PB) {
+        for pb.Next() {
+            logger.Info("This is a benchmark test log message")
+        }
+    })
+    logger.Sync()
+}
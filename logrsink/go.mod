@@ -0,0 +1,10 @@
+module gopkg.in/taichidb/logr.v1/logrsink
+
+go 1.18
+
+require (
+	github.com/go-logr/logr v1.4.3
+	gopkg.in/taichidb/logr.v1 v1.0.0
+)
+
+replace gopkg.in/taichidb/logr.v1 => ../
@@ -0,0 +1,96 @@
+// Package logrsink adapts a logr.Logger to the github.com/go-logr/logr API,
+// so that it can be used by Kubernetes-style code. It lives in its own
+// package to keep the core package free of dependencies.
+package logrsink
+
+import (
+    "fmt"
+
+    gologr "github.com/go-logr/logr"
+    "gopkg.in/taichidb/logr.v1"
+)
+
+// Sink is a go-logr LogSink writing through a logr.Logger. V-level 0 maps
+// to INFO and higher V-levels to DEBUG; Error records are written at ERROR
+// with the error as the "error" field. WithName creates a named logger, so
+// the component levels of the logger apply.
+type Sink struct {
+    logger *logr.Logger
+}
+
+// New returns a go-logr Logger writing to logger
+func New(logger *logr.Logger) gologr.Logger {
+    return gologr.New(NewSink(logger))
+}
+
+// NewSink returns a go-logr LogSink writing to logger
+func NewSink(logger *logr.Logger) *Sink {
+    return &Sink{logger: logger}
+}
+
+// level maps a go-logr V-level onto a LogLevel
+func level(v int) logr.LogLevel {
+    if v <= 0 {
+        return logr.INFO
+    }
+    return logr.DEBUG
+}
+
+// Init implements gologr.LogSink
+func (s *Sink) Init(info gologr.RuntimeInfo) {}
+
+// Enabled implements gologr.LogSink
+func (s *Sink) Enabled(v int) bool {
+    return s.logger.Enabled(level(v))
+}
+
+// Info implements gologr.LogSink
+func (s *Sink) Info(v int, msg string, keysAndValues ...interface{}) {
+    fields := kvFields(nil, keysAndValues)
+    if level(v) == logr.INFO {
+        s.logger.Infow(msg, fields...)
+    } else {
+        s.logger.Debugw(msg, fields...)
+    }
+}
+
+// Error implements gologr.LogSink
+func (s *Sink) Error(err error, msg string, keysAndValues ...interface{}) {
+    var fields []logr.Field
+    if err != nil {
+        fields = append(fields, logr.Error(err))
+    }
+    s.logger.Errorw(msg, kvFields(fields, keysAndValues)...)
+}
+
+// WithValues implements gologr.LogSink
+func (s *Sink) WithValues(keysAndValues ...interface{}) gologr.LogSink {
+    return &Sink{logger: s.logger.With(kvFields(nil, keysAndValues)...)}
+}
+
+// WithName implements gologr.LogSink. Names are joined with a dot, as by
+// logr.Logger.Named.
+func (s *Sink) WithName(name string) gologr.LogSink {
+    return &Sink{logger: s.logger.Named(name)}
+}
+
+// kvFields appends key/value pairs to dst as fields. Keys that are not strings
+// are formatted, and a key without a value gets the value "<no-value>".
+// Values implementing gologr.Marshaler are logged as their MarshalLog value.
+func kvFields(dst []logr.Field, keysAndValues []interface{}) []logr.Field {
+    for i := 0; i < len(keysAndValues); i += 2 {
+        key, ok := keysAndValues[i].(string)
+        if !ok {
+            key = fmt.Sprint(keysAndValues[i])
+        }
+        var value interface{} = "<no-value>"
+        if i+1 < len(keysAndValues) {
+            value = keysAndValues[i+1]
+        }
+        if m, ok := value.(gologr.Marshaler); ok {
+            value = m.MarshalLog()
+        }
+        dst = append(dst, logr.Any(key, value))
+    }
+    return dst
+}
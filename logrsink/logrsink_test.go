@@ -0,0 +1,70 @@
+package logrsink
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "gopkg.in/taichidb/logr.v1"
+)
+
+// secret is a value with its own logged representation
+type secret string
+
+func (s secret) MarshalLog() interface{} {
+    return "***"
+}
+
+func TestSink(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_logrsink"
+    defer os.RemoveAll(tempDir)
+
+    logger, err := logr.NewLogger(&logr.Config{
+        LogDir:   tempDir,
+        FileName: "sink",
+        MaxSize:  1024 * 1024,
+        MaxAge:   time.Hour,
+        Level:    logr.INFO,
+        Encoder:  logr.NewJSONEncoder(),
+    })
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    log := New(logger).WithName("controller").WithValues("namespace", "default")
+    log.Info("reconciling", "pod", "web-1", "attempt", 2)
+    if log.V(1).Enabled() {
+        t.Error("expected V(1) to be disabled at INFO")
+    }
+    log.V(1).Info("verbose")
+    log.WithName("cache").Error(errors.New("connection refused"), "sync failed", "token", secret("t0ken"), "dangling")
+    log.Error(nil, "no error")
+
+    logger.SetLevel(logr.DEBUG)
+    log.V(3).Info("verbose")
+    logger.Close()
+
+    data, err := os.ReadFile(filepath.Join(tempDir, "sink.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+    expected := []string{
+        `"level":"info","msg":"reconciling","logger":"controller","namespace":"default","pod":"web-1","attempt":2}`,
+        `"level":"error","msg":"sync failed","logger":"controller.cache","namespace":"default","error":"connection refused","token":"***","dangling":"<no-value>"}`,
+        `"level":"error","msg":"no error","logger":"controller","namespace":"default"}`,
+        `"level":"debug","msg":"verbose","logger":"controller","namespace":"default"}`,
+    }
+    if len(lines) != len(expected) {
+        t.Fatalf("expected %d records, got %d:\n%s", len(expected), len(lines), data)
+    }
+    for i, line := range lines {
+        if !strings.HasSuffix(line, expected[i]) {
+            t.Errorf("expected record %d to end with %s, got %s", i, expected[i], line)
+        }
+    }
+}
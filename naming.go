@@ -0,0 +1,254 @@
+package logr
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// DefaultBackupNameTemplate is the backup naming template used when Config.BackupNameTemplate is empty.
+//
+// Templates support the following placeholders:
+//
+//     {name}  the configured FileName
+//     {date}  the rotation date as 20060102
+//     {time}  the rotation time as 150405
+//     {seq}   a sequence number that increases with every rotation
+//
+// A template must contain {seq}, which makes backup names unique even for
+// several rotations within the same second. ".gz" is appended to compressed
+// backups, and ".log" is appended if the template does not end with it.
+const DefaultBackupNameTemplate = "{name}_{date}_{time}.{seq}.log"
+
+// backupNamer formats and recognizes backup file names
+type backupNamer struct {
+    name     string
+    template string
+    pattern  *regexp.Regexp
+    legacy   *regexp.Regexp // Matches legacy names without a sequence number
+    seqGroup int
+}
+
+// newBackupNamer creates a namer for the given file name prefix and template
+func newBackupNamer(name, template string) (*backupNamer, error) {
+    if template == "" {
+        template = DefaultBackupNameTemplate
+    }
+    template = strings.TrimSuffix(template, ".gz")
+    if !strings.HasSuffix(template, ".log") {
+        template += ".log"
+    }
+    if !strings.Contains(template, "{seq}") {
+        return nil, fmt.Errorf("backup name template %q must contain {seq}", template)
+    }
+    if strings.ContainsRune(template, filepath.Separator) {
+        return nil, fmt.Errorf("backup name template %q must not contain a path separator", template)
+    }
+
+    // Build a regular expression matching names produced by the template
+    var expr strings.Builder
+    expr.WriteByte('^')
+    seqGroup, group := 0, 0
+    rest := template
+    for rest != "" {
+        start := strings.IndexByte(rest, '{')
+        end := strings.IndexByte(rest, '}')
+        if start < 0 || end < start {
+            expr.WriteString(regexp.QuoteMeta(rest))
+            break
+        }
+        expr.WriteString(regexp.QuoteMeta(rest[:start]))
+        switch token := rest[start : end+1]; token {
+        case "{name}":
+            expr.WriteString(regexp.QuoteMeta(name))
+        case "{date}":
+            expr.WriteString(`\d{8}`)
+        case "{time}":
+            expr.WriteString(`\d{6}`)
+        case "{seq}":
+            group++
+            seqGroup = group
+            expr.WriteString(`(\d+)`)
+        default:
+            return nil, fmt.Errorf("unknown placeholder %s in backup name template", token)
+        }
+        rest = rest[end+1:]
+    }
+    expr.WriteString(`(?:\.gz)?$`)
+
+    pattern, err := regexp.Compile(expr.String())
+    if err != nil {
+        return nil, fmt.Errorf("invalid backup name template %q: %v", template, err)
+    }
+    legacy := regexp.MustCompile(`^` + regexp.QuoteMeta(name) + `_\d{8}_\d{6}\.log(?:\.gz)?$`)
+    return &backupNamer{
+        name:     name,
+        template: template,
+        pattern:  pattern,
+        legacy:   legacy,
+        seqGroup: seqGroup,
+    }, nil
+}
+
+// format returns the uncompressed backup name for a rotation
+func (n *backupNamer) format(timestamp time.Time, seq uint64) string {
+    return strings.NewReplacer(
+        "{name}", n.name,
+        "{date}", timestamp.Format("20060102"),
+        "{time}", timestamp.Format("150405"),
+        "{seq}", strconv.FormatUint(seq, 10),
+    ).Replace(n.template)
+}
+
+// parse reports whether fileName is a backup and returns its sequence number.
+// Legacy names of the form name_20060102_150405.log[.gz] have sequence 0. Only
+// the exact legacy shape is accepted, so the active file of another logger whose
+// name starts with this one, such as name_audit.log, is never taken for a backup.
+func (n *backupNamer) parse(fileName string) (uint64, bool) {
+    if m := n.pattern.FindStringSubmatch(fileName); m != nil {
+        seq, err := strconv.ParseUint(m[n.seqGroup], 10, 64)
+        if err == nil {
+            return seq, true
+        }
+    }
+    if n.legacy.MatchString(fileName) {
+        return 0, true
+    }
+    return 0, false
+}
+
+// logFile describes a log file found in the log directory
+type logFile struct {
+    name    string
+    path    string
+    size    int64
+    modTime time.Time
+    seq     uint64 // Backup sequence number, 0 for legacy backups and the active file
+    active  bool   // Whether this is the active log file
+}
+
+// listLogFiles lists the active log file and its backups in chronological order,
+// oldest backup first and the active file last
+func listLogFiles(dir string, namer *backupNamer) ([]logFile, error) {
+    entries, err := os.ReadDir(dir)
+    if err != nil {
+        return nil, err
+    }
+
+    listed := make(map[string]bool, len(entries))
+    for _, entry := range entries {
+        listed[entry.Name()] = true
+    }
+
+    var files []logFile
+    for _, entry := range entries {
+        if entry.IsDir() {
+            continue
+        }
+
+        name := entry.Name()
+        active := name == namer.name+".log"
+        seq, isBackup := namer.parse(name)
+        if !active && !isBackup {
+            continue
+        }
+        info, err := entry.Info()
+        if err != nil && !active && !strings.HasSuffix(name, ".gz") && !listed[name+".gz"] {
+            // Compressed since the directory was read; list the archive instead
+            name += ".gz"
+            info, err = os.Stat(filepath.Join(dir, name))
+        }
+        if err != nil {
+            continue
+        }
+        files = append(files, logFile{
+            name:    name,
+            path:    filepath.Join(dir, name),
+            size:    info.Size(),
+            modTime: info.ModTime(),
+            seq:     seq,
+            active:  active,
+        })
+    }
+
+    sort.SliceStable(files, func(i, j int) bool {
+        a, b := files[i], files[j]
+        if a.active != b.active {
+            return b.active
+        }
+        if a.seq != b.seq {
+            return a.seq < b.seq
+        }
+        if !a.modTime.Equal(b.modTime) {
+            return a.modTime.Before(b.modTime)
+        }
+        return a.name < b.name
+    })
+    return files, nil
+}
+
+// LogFile describes a log file of a logger configuration
+type LogFile struct {
+    Name    string
+    Path    string
+    Size    int64
+    ModTime time.Time
+    Seq     uint64 // Backup sequence number, 0 for legacy backups and the active file
+    Active  bool   // Whether this is the active log file
+
+    keys KeyProvider // Decrypts the file for TimeRange
+}
+
+// Compressed reports whether the file is a gzip archive
+func (f LogFile) Compressed() bool {
+    return strings.HasSuffix(f.Name, ".gz")
+}
+
+// ListLogFiles lists the active log file and the backups of a logger configuration
+// in chronological order, oldest backup first and the active file last
+func ListLogFiles(config *Config) ([]LogFile, error) {
+    namer, err := newBackupNamer(config.FileName, config.BackupNameTemplate)
+    if err != nil {
+        return nil, err
+    }
+    files, err := listLogFiles(config.LogDir, namer)
+    if err != nil {
+        return nil, err
+    }
+
+    result := make([]LogFile, len(files))
+    for i, file := range files {
+        result[i] = LogFile{
+            Name:    file.name,
+            Path:    file.path,
+            Size:    file.size,
+            ModTime: file.modTime,
+            Seq:     file.seq,
+            Active:  file.active,
+            keys:    config.EncryptionKeys,
+        }
+    }
+    return result, nil
+}
+
+// nextBackupPath allocates a sequence number and returns an unused backup path
+func (l *Logger) nextBackupPath(timestamp time.Time) string {
+    for {
+        seq := l.nextSeq
+        l.nextSeq++
+        path := l.getBackupLogPath(timestamp, seq)
+        plain := strings.TrimSuffix(path, ".gz")
+        if _, err := os.Lstat(plain); err == nil {
+            continue
+        }
+        if _, err := os.Lstat(plain + ".gz"); err == nil {
+            continue
+        }
+        return path
+    }
+}
@@ -0,0 +1,214 @@
+package logr
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestRapidRotationKeepsEveryBackup(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_naming"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:     tempDir,
+        FileName:   "naming_test",
+        MaxSize:    100, // Every record triggers a rotation
+        MaxAge:     time.Hour,
+        MaxBackups: 1000,
+        Level:      INFO,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    const records = 50
+    for i := 0; i < records; i++ {
+        logger.Info("record %03d padded to exceed half of the maximum file size", i)
+    }
+    logger.Sync()
+
+    files, err := logger.getLogFiles()
+    if err != nil {
+        t.Fatalf("failed to list log files: %v", err)
+    }
+    if len(files) != records {
+        t.Fatalf("expected %d log files, got %d", records, len(files))
+    }
+
+    // Every record must survive, in order, even though all rotations share a second
+    for i, file := range files {
+        content, err := os.ReadFile(file.path)
+        if err != nil {
+            t.Fatalf("failed to read %s: %v", file.name, err)
+        }
+        if !strings.Contains(string(content), fmt.Sprintf("record %03d", i)) {
+            t.Errorf("file %s does not contain record %d: %q", file.name, i, content)
+        }
+    }
+}
+
+func TestBackupNameTemplate(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_template"
+    defer os.RemoveAll(tempDir)
+
+    if err := os.MkdirAll(tempDir, 0755); err != nil {
+        t.Fatalf("failed to create directory: %v", err)
+    }
+
+    // A backup written with the legacy naming scheme
+    legacy := filepath.Join(tempDir, "tpl_20200101_000000.log.gz")
+    writeGzip(t, legacy, "legacy record\n")
+    old := time.Now().Add(-time.Minute)
+    os.Chtimes(legacy, old, old)
+
+    config := &Config{
+        LogDir:             tempDir,
+        FileName:           "tpl",
+        MaxSize:            100,
+        MaxAge:             time.Hour,
+        MaxBackups:         100,
+        Level:              INFO,
+        Compress:           true,
+        BackupNameTemplate: "{name}-{date}.{seq}.log.gz",
+    }
+
+    for run := 0; run < 2; run++ {
+        logger, err := NewLogger(config)
+        if err != nil {
+            t.Fatalf("failed to create logger: %v", err)
+        }
+        for i := 0; i < 3; i++ {
+            logger.Info("template record %d with enough padding to rotate", i)
+        }
+        logger.Close()
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    files, err := logger.getLogFiles()
+    if err != nil {
+        t.Fatalf("failed to list log files: %v", err)
+    }
+
+    pattern := regexp.MustCompile(`^tpl-\d{8}\.(\d+)\.log\.gz$`)
+    if files[0].name != filepath.Base(legacy) {
+        t.Errorf("expected the legacy backup first, got %s", files[0].name)
+    }
+    if !files[len(files)-1].active {
+        t.Errorf("expected the active file last, got %s", files[len(files)-1].name)
+    }
+    for i, file := range files[1 : len(files)-1] {
+        m := pattern.FindStringSubmatch(file.name)
+        if m == nil {
+            t.Errorf("backup %s does not match the template", file.name)
+            continue
+        }
+        if m[1] != strconv.Itoa(i+1) {
+            t.Errorf("expected sequence %d to continue across restarts, got %s", i+1, file.name)
+        }
+    }
+
+    if _, err := NewLogger(&Config{LogDir: tempDir, FileName: "bad", BackupNameTemplate: "{name}-{date}.log"}); err == nil {
+        t.Error("expected an error for a template without {seq}")
+    }
+}
+
+func TestPrefixRelatedLoggersShareDirectory(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_shared"
+    defer os.RemoveAll(tempDir)
+
+    newConfig := func(name string) *Config {
+        return &Config{
+            LogDir:     tempDir,
+            FileName:   name,
+            MaxSize:    100,
+            MaxAge:     time.Hour,
+            MaxBackups: 100,
+            Level:      INFO,
+        }
+    }
+
+    audit, err := NewLogger(newConfig("app_audit"))
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer audit.Close()
+    app, err := NewLogger(newConfig("app"))
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer app.Close()
+
+    for i := 0; i < 3; i++ {
+        audit.Info("audit record %d with enough padding to rotate the file", i)
+        app.Info("app record %d with enough padding to rotate the file", i)
+    }
+    audit.Sync()
+    app.Sync()
+
+    // A legacy backup of app must still be recognized
+    legacy := filepath.Join(tempDir, "app_20200101_000000.log")
+    if err := os.WriteFile(legacy, []byte("legacy record\n"), 0644); err != nil {
+        t.Fatalf("failed to write legacy backup: %v", err)
+    }
+
+    for _, tc := range []struct {
+        logger *Logger
+        name   string
+        files  int
+    }{
+        {app, "app", 4},
+        {audit, "app_audit", 3},
+    } {
+        files, err := tc.logger.getLogFiles()
+        if err != nil {
+            t.Fatalf("failed to list log files: %v", err)
+        }
+        for _, file := range files {
+            if file.name == "app_20200101_000000.log" && tc.name == "app" {
+                continue
+            }
+            if !strings.HasPrefix(file.name, tc.name+"_2") && file.name != tc.name+".log" {
+                t.Errorf("logger %s claims file %s", tc.name, file.name)
+            }
+        }
+        if len(files) != tc.files {
+            t.Errorf("expected %d files for logger %s, got %d", tc.files, tc.name, len(files))
+        }
+    }
+
+    namer, err := newBackupNamer("app", "")
+    if err != nil {
+        t.Fatalf("failed to create namer: %v", err)
+    }
+    for name, want := range map[string]bool{
+        "app_20200101_000000.log":        true,
+        "app_20200101_000000.log.gz":     true,
+        "app_20200101_000000.7.log.gz":   true,
+        "app_audit.log":                  false,
+        "app_audit.log.gz":               false,
+        "app_audit_20200101_000000.log":  false,
+        "app_2020_000000.log":            false,
+        "app_20200101_000000.log.gz.sig": false,
+    } {
+        if _, ok := namer.parse(name); ok != want {
+            t.Errorf("parse(%q) = %v, expected %v", name, ok, want)
+        }
+    }
+}
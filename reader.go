@@ -0,0 +1,498 @@
+package logr
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// ParseLevel parses a level name such as "INFO" or "warn"
+func ParseLevel(s string) (LogLevel, error) {
+    switch strings.ToUpper(strings.TrimSpace(s)) {
+    case "DEBUG":
+        return DEBUG, nil
+    case "INFO":
+        return INFO, nil
+    case "WARN", "WARNING":
+        return WARN, nil
+    case "ERROR":
+        return ERROR, nil
+    case "FATAL":
+        return FATAL, nil
+    default:
+        return DEBUG, fmt.Errorf("unknown log level %q", s)
+    }
+}
+
+// Record is a log entry read back from a log file
+type Record struct {
+    Entry
+    File string // Path of the log file the record was read from
+    Line int    // 1-based line number within the file, 0 if unknown after seeking
+    Raw  string // The record as written, with its continuation lines, without the trailing newline
+}
+
+// ParseError reports a line that could not be parsed as a log record.
+// Reading can continue with the next call to Next.
+type ParseError struct {
+    File string
+    Line int
+    Raw  string
+    Err  error
+}
+
+// Error implements error
+func (e *ParseError) Error() string {
+    return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+// Reader iterates log records across backups and the active file in chronological order,
+// transparently decompressing gzip archives
+type Reader struct {
+    files []logFile
+    next  int // Index of the next file to open
+
+    file   *os.File
+    reader *bufio.Reader
+    path   string
+    line   int
+
+    from, to time.Time // Time range set by SetRange
+    keys     KeyProvider // Decrypts encrypted files, nil if not given
+}
+
+// NewReader creates a reader for the log files named name in dir,
+// using the default backup naming template
+func NewReader(dir, name string) (*Reader, error) {
+    return OpenReader(&Config{LogDir: dir, FileName: name})
+}
+
+// OpenReader creates a reader for the log files of a logger configuration.
+// Encrypted files are decrypted with config.EncryptionKeys; of an active file
+// encrypted in segments, only the sealed segments can be read.
+func OpenReader(config *Config) (*Reader, error) {
+    namer, err := newBackupNamer(config.FileName, config.BackupNameTemplate)
+    if err != nil {
+        return nil, err
+    }
+    files, err := listLogFiles(config.LogDir, namer)
+    if err != nil {
+        return nil, fmt.Errorf("failed to list log files: %v", err)
+    }
+    return &Reader{files: files, keys: config.EncryptionKeys}, nil
+}
+
+// SetRange restricts the reader to records with from <= Time < to; a zero
+// from or to leaves that end open. Compressed backups are skipped or entered
+// at the nearest checkpoint using their time index, and uncompressed files are
+// binary searched; encrypted files and archives without an index are scanned.
+// SetRange must be called before the first call to Next.
+func (r *Reader) SetRange(from, to time.Time) {
+    r.from, r.to = from, to
+}
+
+// Next returns the next record, or io.EOF after the last one.
+// A *ParseError is returned for lines that are not log records.
+func (r *Reader) Next() (*Record, error) {
+    for {
+        if r.reader == nil {
+            if r.next >= len(r.files) {
+                return nil, io.EOF
+            }
+            if err := r.open(r.files[r.next]); err != nil {
+                return nil, err
+            }
+            r.next++
+            if r.reader == nil {
+                continue
+            }
+        }
+
+        raw, err := r.reader.ReadString('\n')
+        if err != nil && err != io.EOF {
+            r.closeFile()
+            return nil, fmt.Errorf("failed to read %s: %w", r.path, err)
+        }
+        if err == io.EOF {
+            r.closeFile()
+            if raw == "" {
+                continue
+            }
+        }
+        if r.line >= 0 {
+            r.line++
+        }
+        line := r.lineNumber()
+
+        raw = strings.TrimSuffix(strings.TrimSuffix(raw, "\n"), "\r")
+        if raw == "" {
+            continue
+        }
+        raw += r.readContinuations()
+        entry, perr := ParseLine(raw)
+        if perr != nil {
+            return nil, &ParseError{File: r.path, Line: line, Raw: raw, Err: perr}
+        }
+        if entry.Time.Before(r.from) {
+            continue
+        }
+        if !r.to.IsZero() && !entry.Time.Before(r.to) {
+            // Records are chronological, so the rest of the file is out of range
+            r.closeFile()
+            continue
+        }
+        return &Record{Entry: *entry, File: r.path, Line: line, Raw: raw}, nil
+    }
+}
+
+// readContinuations reads the continuation lines that follow a record written
+// with MultilineIndent and returns them, each after a newline
+func (r *Reader) readContinuations() string {
+    var more string
+    for r.reader != nil {
+        if next, err := r.reader.Peek(len(ContinuationMarker)); err != nil || string(next) != ContinuationMarker {
+            break
+        }
+        raw, err := r.reader.ReadString('\n')
+        if r.line >= 0 {
+            r.line++
+        }
+        more += "\n" + strings.TrimSuffix(strings.TrimSuffix(raw, "\n"), "\r")
+        if err != nil {
+            break
+        }
+    }
+    return more
+}
+
+// File returns the path of the file currently being read
+func (r *Reader) File() string {
+    return r.path
+}
+
+// Close releases the file currently being read
+func (r *Reader) Close() error {
+    r.next = len(r.files)
+    return r.closeFile()
+}
+
+// open opens a log file at the position given by the time range, leaving
+// r.reader nil if the file disappeared since it was listed or holds no
+// records in range
+func (r *Reader) open(lf logFile) error {
+    f, err := os.Open(lf.path)
+    if err != nil {
+        if os.IsNotExist(err) {
+            return nil
+        }
+        return fmt.Errorf("failed to open %s: %v", lf.path, err)
+    }
+    return r.openFile(f, lf)
+}
+
+// openFile starts reading an opened log file, taking ownership of f
+func (r *Reader) openFile(f *os.File, lf logFile) error {
+    var src io.Reader = f
+    line := 0
+    if isEncrypted(f) {
+        // Encrypted files are scanned from the start, as seeking needs the plaintext
+        plain, err := newDecryptReader(f, r.keys, !lf.active)
+        if err != nil {
+            f.Close()
+            return fmt.Errorf("failed to decrypt %s: %w", lf.path, err)
+        }
+        src = plain
+        if strings.HasSuffix(lf.name, ".gz") {
+            zr, err := gzip.NewReader(plain)
+            if err != nil {
+                f.Close()
+                return fmt.Errorf("failed to decompress %s: %v", lf.path, err)
+            }
+            src = zr
+        }
+    } else if strings.HasSuffix(lf.name, ".gz") {
+        var offset int64
+        if r.ranged() {
+            if idx, err := readIndex(f); err == nil {
+                if len(idx.checkpoints) == 0 || idx.last.Before(r.from) || (!r.to.IsZero() && !idx.first.Before(r.to)) {
+                    f.Close()
+                    return nil
+                }
+                cp := idx.seek(r.from)
+                offset, line = cp.offset, int(cp.line)-1
+            }
+        }
+        if _, err := f.Seek(offset, io.SeekStart); err != nil {
+            f.Close()
+            return fmt.Errorf("failed to seek %s: %v", lf.path, err)
+        }
+        zr, err := gzip.NewReader(f)
+        if err != nil {
+            f.Close()
+            return fmt.Errorf("failed to decompress %s: %v", lf.path, err)
+        }
+        src = zr
+    } else if !r.from.IsZero() {
+        info, err := f.Stat()
+        if err != nil {
+            f.Close()
+            return fmt.Errorf("failed to stat %s: %v", lf.path, err)
+        }
+        if offset := seekPlain(f, info.Size(), r.from); offset > 0 {
+            src = io.NewSectionReader(f, offset, info.Size()-offset)
+            line = -1
+        }
+    }
+
+    r.file = f
+    r.reader = bufio.NewReaderSize(src, 64*1024)
+    r.path = lf.path
+    r.line = line
+    return nil
+}
+
+// lineNumber returns the current line number, 0 if unknown after seeking
+func (r *Reader) lineNumber() int {
+    if r.line < 0 {
+        return 0
+    }
+    return r.line
+}
+
+// ranged reports whether a time range is set
+func (r *Reader) ranged() bool {
+    return !r.from.IsZero() || !r.to.IsZero()
+}
+
+// closeFile closes the file currently being read
+func (r *Reader) closeFile() error {
+    r.reader = nil
+    if r.file == nil {
+        return nil
+    }
+    err := r.file.Close()
+    r.file = nil
+    return err
+}
+
+// ParseLine parses a single log line written by the text, JSON or logfmt encoder
+func ParseLine(line string) (*Entry, error) {
+    switch {
+    case strings.HasPrefix(line, "{"):
+        return parseJSONLine(line)
+    case strings.HasPrefix(line, "ts="):
+        return parseLogfmtLine(line)
+    case strings.HasPrefix(line, "["):
+        return parseTextLine(line)
+    default:
+        return nil, errors.New("unrecognized log line format")
+    }
+}
+
+// parseTextLine parses "[timestamp] [LEVEL] message"
+func parseTextLine(line string) (*Entry, error) {
+    end := strings.IndexByte(line, ']')
+    if end < 0 {
+        return nil, errors.New("missing timestamp")
+    }
+    ts, err := time.ParseInLocation(textTimeLayout, line[1:end], time.Local)
+    if err != nil {
+        return nil, fmt.Errorf("invalid timestamp: %v", err)
+    }
+
+    rest := line[end+1:]
+    if !strings.HasPrefix(rest, " [") {
+        return nil, errors.New("missing level")
+    }
+    end = strings.IndexByte(rest, ']')
+    if end < 0 {
+        return nil, errors.New("missing level")
+    }
+    level, err := ParseLevel(rest[2:end])
+    if err != nil {
+        return nil, err
+    }
+
+    return &Entry{
+        Time:    ts,
+        Level:   level,
+        Message: unescapeText(strings.TrimPrefix(rest[end+1:], " ")),
+    }, nil
+}
+
+// unescapeText reverses the escaping of the text encoder and joins the
+// continuation lines of a multiline message; unknown escapes are kept as written
+func unescapeText(s string) string {
+    s = strings.ReplaceAll(s, "\n"+ContinuationMarker, "\n")
+    if strings.IndexByte(s, '\\') < 0 {
+        return s
+    }
+
+    var sb strings.Builder
+    for i := 0; i < len(s); i++ {
+        if s[i] != '\\' || i+1 == len(s) {
+            sb.WriteByte(s[i])
+            continue
+        }
+        switch s[i+1] {
+        case '\\':
+            sb.WriteByte('\\')
+        case 'n':
+            sb.WriteByte('\n')
+        case 'r':
+            sb.WriteByte('\r')
+        case 'x', 'u':
+            digits := 2
+            if s[i+1] == 'u' {
+                digits = 4
+            }
+            if i+2+digits > len(s) {
+                sb.WriteByte(s[i])
+                continue
+            }
+            v, err := strconv.ParseUint(s[i+2:i+2+digits], 16, 32)
+            if err != nil {
+                sb.WriteByte(s[i])
+                continue
+            }
+            sb.WriteRune(rune(v))
+            i += digits
+        default:
+            sb.WriteByte(s[i])
+            continue
+        }
+        i++
+    }
+    return sb.String()
+}
+
+// parseJSONLine parses a JSON line, keeping extra keys as fields in order
+func parseJSONLine(line string) (*Entry, error) {
+    dec := json.NewDecoder(strings.NewReader(line))
+    dec.UseNumber()
+    if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+        return nil, errors.New("invalid JSON object")
+    }
+
+    entry := &Entry{}
+    seen := make(map[string]bool)
+    for dec.More() {
+        tok, err := dec.Token()
+        if err != nil {
+            return nil, fmt.Errorf("invalid JSON object: %v", err)
+        }
+        key, _ := tok.(string)
+        var value interface{}
+        if err := dec.Decode(&value); err != nil {
+            return nil, fmt.Errorf("invalid JSON value for %q: %v", key, err)
+        }
+        if err := entry.setParsed(key, value, seen); err != nil {
+            return nil, err
+        }
+    }
+    if entry.Time.IsZero() {
+        return nil, errors.New("missing ts")
+    }
+    return entry, nil
+}
+
+// parseLogfmtLine parses a logfmt line, keeping extra keys as string fields in order
+func parseLogfmtLine(line string) (*Entry, error) {
+    entry := &Entry{}
+    seen := make(map[string]bool)
+    rest := line
+    for rest != "" {
+        rest = strings.TrimLeft(rest, " ")
+        eq := strings.IndexByte(rest, '=')
+        if eq <= 0 {
+            return nil, errors.New("invalid logfmt pair")
+        }
+        key := rest[:eq]
+        rest = rest[eq+1:]
+
+        var value string
+        if strings.HasPrefix(rest, `"`) {
+            end := closingQuote(rest)
+            unquoted, err := strconv.Unquote(rest[:end])
+            if err != nil {
+                return nil, fmt.Errorf("invalid quoted value for %q: %v", key, err)
+            }
+            value, rest = unquoted, rest[end:]
+        } else if sp := strings.IndexByte(rest, ' '); sp >= 0 {
+            value, rest = rest[:sp], rest[sp:]
+        } else {
+            value, rest = rest, ""
+        }
+        if err := entry.setParsed(key, value, seen); err != nil {
+            return nil, err
+        }
+    }
+    if entry.Time.IsZero() {
+        return nil, errors.New("missing ts")
+    }
+    return entry, nil
+}
+
+// closingQuote returns the index just past the quoted string at the start of s,
+// or len(s) if it is not terminated
+func closingQuote(s string) int {
+    for i := 1; i < len(s); i++ {
+        switch s[i] {
+        case '\\':
+            i++
+        case '"':
+            return i + 1
+        }
+    }
+    return len(s)
+}
+
+// setParsed sets a parsed key/value pair, mapping the first ts, level and msg
+// onto the entry; seen records which of them were set. Later duplicates, which
+// the encoders never write, are kept as fields so they cannot forge a record.
+func (e *Entry) setParsed(key string, value interface{}, seen map[string]bool) error {
+    if !seen[key] {
+        str, isString := value.(string)
+        switch key {
+        case "ts":
+            ts, err := time.Parse(jsonTimeLayout, str)
+            if !isString || err != nil {
+                return fmt.Errorf("invalid ts %v", value)
+            }
+            e.Time = ts
+            seen[key] = true
+            return nil
+        case "level":
+            level, err := ParseLevel(str)
+            if err != nil {
+                return err
+            }
+            e.Level = level
+            seen[key] = true
+            return nil
+        case "msg":
+            e.Message = str
+            seen[key] = true
+            return nil
+        }
+    }
+
+    if n, ok := value.(json.Number); ok {
+        if i, err := n.Int64(); err == nil {
+            e.Fields = append(e.Fields, Int64(key, i))
+            return nil
+        }
+        f, _ := n.Float64()
+        e.Fields = append(e.Fields, Any(key, f))
+        return nil
+    }
+    e.Fields = append(e.Fields, Any(key, value))
+    return nil
+}
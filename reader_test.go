@@ -0,0 +1,313 @@
+package logr
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestReaderAcrossBackups(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_reader"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:     tempDir,
+        FileName:   "reader_test",
+        MaxSize:    200,
+        MaxAge:     time.Hour,
+        MaxBackups: 100,
+        Level:      DEBUG,
+        Compress:   true,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    const count = 20
+    for i := 0; i < count; i++ {
+        logger.Warn("record %02d", i)
+    }
+    logger.Close()
+
+    // A line that is not a log record is reported but does not stop reading
+    f, err := os.OpenFile(filepath.Join(tempDir, "reader_test.log"), os.O_APPEND|os.O_WRONLY, 0644)
+    if err != nil {
+        t.Fatalf("failed to open log file: %v", err)
+    }
+    f.WriteString("not a log record\n")
+    f.WriteString("[2024-01-02 03:04:05.000] [ERROR] last record")
+    f.Close()
+
+    reader, err := OpenReader(config)
+    if err != nil {
+        t.Fatalf("failed to open reader: %v", err)
+    }
+    defer reader.Close()
+
+    var records []*Record
+    var parseErrors int
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            break
+        }
+        var perr *ParseError
+        if errors.As(err, &perr) {
+            parseErrors++
+            if perr.Raw != "not a log record" {
+                t.Errorf("unexpected parse error: %v", perr)
+            }
+            continue
+        }
+        if err != nil {
+            t.Fatalf("failed to read record: %v", err)
+        }
+        records = append(records, record)
+    }
+
+    if parseErrors != 1 {
+        t.Errorf("expected 1 parse error, got %d", parseErrors)
+    }
+    if len(records) != count+1 {
+        t.Fatalf("expected %d records, got %d", count+1, len(records))
+    }
+    compressed := 0
+    for i := 0; i < count; i++ {
+        if records[i].Message != fmt.Sprintf("record %02d", i) || records[i].Level != WARN {
+            t.Errorf("unexpected record %d: %+v", i, records[i])
+        }
+        if i > 0 && records[i].Time.Before(records[i-1].Time) {
+            t.Errorf("record %d is out of order", i)
+        }
+        if filepath.Ext(records[i].File) == ".gz" {
+            compressed++
+        }
+    }
+    if compressed == 0 {
+        t.Error("expected records to be read from compressed backups")
+    }
+    last := records[count]
+    if last.Message != "last record" || last.Level != ERROR || last.Time.Year() != 2024 {
+        t.Errorf("unexpected unterminated last record: %+v", last)
+    }
+}
+
+func TestParseLine(t *testing.T) {
+    entry := &Entry{
+        Time:    time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.UTC),
+        Level:   ERROR,
+        Message: "disk \"full\"",
+        Fields:  []Field{String("user", "bob smith"), Int("rows", 3)},
+    }
+
+    for name, encoder := range map[string]Encoder{
+        "json":   NewJSONEncoder(),
+        "logfmt": NewLogfmtEncoder(),
+    } {
+        line, err := encoder.Encode(entry)
+        if err != nil {
+            t.Fatalf("%s: failed to encode entry: %v", name, err)
+        }
+        parsed, err := ParseLine(string(line[:len(line)-1]))
+        if err != nil {
+            t.Fatalf("%s: failed to parse %q: %v", name, line, err)
+        }
+        if !parsed.Time.Equal(entry.Time) || parsed.Level != ERROR || parsed.Message != entry.Message {
+            t.Errorf("%s: unexpected entry: %+v", name, parsed)
+        }
+        if len(parsed.Fields) != 2 || parsed.Fields[0].Key != "user" || parsed.Fields[0].ValueString() != "bob smith" ||
+            parsed.Fields[1].Key != "rows" || parsed.Fields[1].ValueString() != "3" {
+            t.Errorf("%s: unexpected fields: %+v", name, parsed.Fields)
+        }
+    }
+
+    if _, err := ParseLine("[2024-01-02 03:04:05.000] [LOUD] what"); err == nil {
+        t.Error("expected an unknown level to be rejected")
+    }
+}
+
+func TestReaderRange(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_reader_range"
+    defer os.RemoveAll(tempDir)
+
+    if err := os.MkdirAll(tempDir, 0755); err != nil {
+        t.Fatalf("failed to create directory: %v", err)
+    }
+
+    // Four files of one record per second: two indexed archives,
+    // an uncompressed backup and the active file
+    start := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)
+    const perFile = 3000
+    names := []string{
+        "range_20240102_000000.1.log",
+        "range_20240102_005000.2.log",
+        "range_20240102_014000.3.log",
+        "range.log",
+    }
+    encoder := NewTextEncoder()
+    for i, name := range names {
+        var content []byte
+        for j := 0; j < perFile; j++ {
+            n := i*perFile + j
+            line, _ := encoder.Encode(&Entry{
+                Time:    start.Add(time.Duration(n) * time.Second),
+                Level:   INFO,
+                Message: fmt.Sprintf("record %05d", n),
+            })
+            content = append(content, line...)
+        }
+        path := filepath.Join(tempDir, name)
+        if err := os.WriteFile(path, content, 0644); err != nil {
+            t.Fatalf("failed to write %s: %v", name, err)
+        }
+        if i < 2 {
+            if err := compressFile(path, path+".gz", nil); err != nil {
+                t.Fatalf("failed to compress %s: %v", name, err)
+            }
+        }
+    }
+
+    f, err := os.Open(filepath.Join(tempDir, names[1]+".gz"))
+    if err != nil {
+        t.Fatalf("failed to open archive: %v", err)
+    }
+    idx, err := readIndex(f)
+    f.Close()
+    if err != nil {
+        t.Fatalf("failed to read time index: %v", err)
+    }
+    if !idx.first.Equal(start.Add(perFile*time.Second)) || !idx.last.Equal(start.Add((2*perFile-1)*time.Second)) {
+        t.Errorf("unexpected index bounds: %v - %v", idx.first, idx.last)
+    }
+    if len(idx.checkpoints) < 2 {
+        t.Errorf("expected several checkpoints, got %d", len(idx.checkpoints))
+    }
+
+    tests := []struct {
+        from, to int
+    }{
+        {4000, 4010},   // Inside the second archive
+        {5990, 6010},   // Across the second archive and the plain backup
+        {8000, 8001},   // Inside the plain backup
+        {11500, 12000}, // Up to the end of the active file
+        {0, 3},         // Start of the first archive
+    }
+    for _, tt := range tests {
+        reader, err := OpenReader(&Config{LogDir: tempDir, FileName: "range"})
+        if err != nil {
+            t.Fatalf("failed to open reader: %v", err)
+        }
+        reader.SetRange(start.Add(time.Duration(tt.from)*time.Second), start.Add(time.Duration(tt.to)*time.Second))
+
+        var got []string
+        for {
+            record, err := reader.Next()
+            if err == io.EOF {
+                break
+            }
+            if err != nil {
+                t.Fatalf("failed to read record: %v", err)
+            }
+            got = append(got, record.Message)
+
+            // Line numbers survive seeking through the index
+            var n int
+            fmt.Sscanf(record.Message, "record %d", &n)
+            if filepath.Ext(record.File) == ".gz" && record.Line != n%perFile+1 {
+                t.Errorf("unexpected line number %d for %q", record.Line, record.Message)
+            }
+        }
+        reader.Close()
+
+        if len(got) != tt.to-tt.from {
+            t.Errorf("[%d, %d): expected %d records, got %d", tt.from, tt.to, tt.to-tt.from, len(got))
+            continue
+        }
+        for i, msg := range got {
+            if msg != fmt.Sprintf("record %05d", tt.from+i) {
+                t.Errorf("[%d, %d): unexpected record %q at %d", tt.from, tt.to, msg, i)
+                break
+            }
+        }
+    }
+}
+
+func TestReaderMultiline(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_reader_multiline"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:    tempDir,
+        FileName:  "multiline",
+        MaxSize:   1024 * 1024,
+        Level:     DEBUG,
+        Encoder:   &TextEncoder{Multiline: MultilineIndent},
+        HashChain: true,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    var mu sync.Mutex
+    var followed []*Record
+    follower, err := Follow(config, FollowOptions{FromStart: true, PollInterval: time.Millisecond}, func(record *Record) {
+        mu.Lock()
+        defer mu.Unlock()
+        followed = append(followed, record)
+    })
+    if err != nil {
+        t.Fatalf("failed to follow: %v", err)
+    }
+
+    trace := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/main.go:5"
+    logger.Info("before")
+    logger.Errorw(trace, String("query", "SELECT 1"))
+    logger.Info("after")
+    logger.Close()
+    follower.Close()
+
+    reader, err := OpenReader(config)
+    if err != nil {
+        t.Fatalf("failed to open reader: %v", err)
+    }
+    defer reader.Close()
+
+    var records []*Record
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            t.Fatalf("failed to read: %v", err)
+        }
+        records = append(records, record)
+    }
+
+    // The chain start record comes first
+    for name, got := range map[string][]*Record{"reader": records, "follow": followed} {
+        if len(got) != 4 {
+            t.Fatalf("%s: expected 4 records, got %d", name, len(got))
+        }
+        if got[2].Level != ERROR || !strings.HasPrefix(got[2].Message, trace+" query=") {
+            t.Errorf("%s: multiline message not joined: %q", name, got[2].Message)
+        }
+        if got[2].Line != 3 || got[3].Line != 8 || !strings.HasPrefix(got[3].Message, "after ") {
+            t.Errorf("%s: unexpected line numbers %d and %d", name, got[2].Line, got[3].Line)
+        }
+    }
+    if err := VerifyFiles(config); err != nil {
+        t.Errorf("expected an intact chain across multiline records, got %v", err)
+    }
+}
@@ -0,0 +1,324 @@
+package logr
+
+import (
+    "compress/gzip"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// QuarantineDirName is the subdirectory of LogDir that corrupted archives are moved to
+const QuarantineDirName = "quarantine"
+
+// RecoveryAction identifies a repair made when the logger starts
+type RecoveryAction int
+
+const (
+    RecoveryRemovedTemp      RecoveryAction = iota // Removed a partial temporary archive
+    RecoveryRecompressed                           // Replaced a corrupted archive by recompressing its source
+    RecoveryQuarantined                            // Moved a corrupted archive without source to the quarantine directory
+    RecoveryCompressedOrphan                       // Scheduled compression of an uncompressed backup
+    RecoveryRemovedDuplicate                       // Removed an uncompressed backup whose archive is complete
+    RecoverySigned                                 // Scheduled signing of a backup whose signing was interrupted
+    RecoveryEncrypted                              // Scheduled encryption of a backup left unencrypted
+    RecoveryLeftUnsigned                           // Left a backup that may have been tampered with as it is rather than sign it
+)
+
+// String returns the string representation of the recovery action
+func (a RecoveryAction) String() string {
+    switch a {
+    case RecoveryRemovedTemp:
+        return "removed-temp"
+    case RecoveryRecompressed:
+        return "recompressed"
+    case RecoveryQuarantined:
+        return "quarantined"
+    case RecoveryCompressedOrphan:
+        return "compressed-orphan"
+    case RecoveryRemovedDuplicate:
+        return "removed-duplicate"
+    case RecoverySigned:
+        return "signed"
+    case RecoveryEncrypted:
+        return "encrypted"
+    case RecoveryLeftUnsigned:
+        return "left-unsigned"
+    default:
+        return "unknown"
+    }
+}
+
+// RecoveryEvent describes a repair made to the log directory on startup
+type RecoveryEvent struct {
+    Action RecoveryAction
+    Path   string // File the action was applied to
+    Err    error  // Why the file needed repair, if it was damaged
+}
+
+// recoverLogDir repairs the effects of rotations and compressions that were
+// interrupted by a crash: partial temporary archives, encrypted files and
+// manifests, archives with an invalid gzip trailer, and rotated files that
+// were never compressed, encrypted or signed. Other loggers may share the log
+// directory, so only files whose names strictly match this logger's backup
+// pattern, and their temporary files, are ever touched.
+func (l *Logger) recoverLogDir() error {
+    entries, err := os.ReadDir(l.config.LogDir)
+    if err != nil {
+        return fmt.Errorf("failed to read log directory: %v", err)
+    }
+
+    recompress := make(map[string]bool)
+    names := make(map[string]bool, len(entries))
+    for _, entry := range entries {
+        if !entry.IsDir() {
+            names[entry.Name()] = true
+        }
+    }
+
+    // The newest archive is the one a crash can have cut short
+    var newest string
+    if files, err := l.getLogFiles(); err == nil {
+        for _, file := range files {
+            if strings.HasSuffix(file.name, ".gz") {
+                newest = file.name
+            }
+        }
+    }
+
+    for _, entry := range entries {
+        name := entry.Name()
+        if entry.IsDir() || name == l.config.FileName+".log" {
+            continue
+        }
+        path := filepath.Join(l.config.LogDir, name)
+
+        switch {
+        case strings.HasSuffix(name, SignatureExt+".tmp"):
+            // Signing never got to publish the manifest; the backup is signed again below
+            if _, ok := l.namer.parse(strings.TrimSuffix(name, SignatureExt+".tmp")); !ok {
+                continue
+            }
+            if err := os.Remove(path); err != nil {
+                return fmt.Errorf("failed to remove partial signature manifest: %v", err)
+            }
+            l.reportRecovery(RecoveryEvent{Action: RecoveryRemovedTemp, Path: path})
+
+        case strings.HasSuffix(name, encryptTempExt):
+            // Encryption never got to publish the file; its source is handled below
+            if _, ok := l.namer.parse(strings.TrimSuffix(name, encryptTempExt)); !ok {
+                continue
+            }
+            if err := os.Remove(path); err != nil {
+                return fmt.Errorf("failed to remove partial encrypted file: %v", err)
+            }
+            l.reportRecovery(RecoveryEvent{Action: RecoveryRemovedTemp, Path: path})
+
+        case strings.HasSuffix(name, ".log.gz.tmp"):
+            // Compression never got to publish the archive; the source is handled below
+            if _, ok := l.namer.parse(strings.TrimSuffix(name, ".tmp")); !ok {
+                continue
+            }
+            if err := os.Remove(path); err != nil {
+                return fmt.Errorf("failed to remove partial archive: %v", err)
+            }
+            l.reportRecovery(RecoveryEvent{Action: RecoveryRemovedTemp, Path: path})
+
+        case strings.HasSuffix(name, ".log.gz"):
+            if _, ok := l.namer.parse(name); !ok {
+                continue
+            }
+            if name != newest && !needsValidation(path, names) {
+                continue
+            }
+            verr := validateArchive(path, l.config.EncryptionKeys)
+            if verr == nil {
+                continue
+            }
+            if source := strings.TrimSuffix(name, ".gz"); names[source] {
+                // The source still exists; it is compressed again below
+                if err := removeBackup(path); err != nil {
+                    return fmt.Errorf("failed to remove corrupted archive: %v", err)
+                }
+                delete(names, name)
+                recompress[source] = true
+                l.reportRecovery(RecoveryEvent{Action: RecoveryRecompressed, Path: path, Err: verr})
+                continue
+            }
+            quarantined, err := quarantineFile(l.config.LogDir, path)
+            if err != nil {
+                return err
+            }
+            l.reportRecovery(RecoveryEvent{Action: RecoveryQuarantined, Path: quarantined, Err: verr})
+        }
+    }
+
+    // Uncompressed backups are only orphans when compression is enabled
+    if l.config.Compress {
+        for _, entry := range entries {
+            name := entry.Name()
+            if entry.IsDir() || name == l.config.FileName+".log" || !strings.HasSuffix(name, ".log") {
+                continue
+            }
+            if _, ok := l.namer.parse(name); !ok {
+                continue
+            }
+            path := filepath.Join(l.config.LogDir, name)
+            if names[name+".gz"] {
+                // The archive was published but the source was never removed
+                if err := removeBackup(path); err != nil {
+                    return fmt.Errorf("failed to remove compressed backup source: %v", err)
+                }
+                l.reportRecovery(RecoveryEvent{Action: RecoveryRemovedDuplicate, Path: path})
+                continue
+            }
+            if err := l.resignError(path); err != nil {
+                l.reportRecovery(RecoveryEvent{Action: RecoveryLeftUnsigned, Path: path, Err: err})
+                continue
+            }
+            l.enqueueCompression(path, path+".gz")
+            if !recompress[name] {
+                l.reportRecovery(RecoveryEvent{Action: RecoveryCompressedOrphan, Path: path})
+            }
+        }
+    }
+
+    if l.config.SigningKeys != nil || l.config.EncryptionKeys != nil {
+        if err := l.recoverBackups(); err != nil {
+            return err
+        }
+    }
+    if l.config.SigningKeys != nil {
+        l.pruneJournal()
+    }
+    return nil
+}
+
+// recoverBackups schedules encryption of finished backups left unencrypted and
+// signing of those whose signing was interrupted; backups waiting for
+// compression are encrypted and signed afterwards
+func (l *Logger) recoverBackups() error {
+    files, err := l.getLogFiles()
+    if err != nil {
+        return fmt.Errorf("failed to list log files: %v", err)
+    }
+    for _, file := range files {
+        if file.active || (l.config.Compress && !strings.HasSuffix(file.name, ".gz")) {
+            continue
+        }
+        if l.config.EncryptionKeys != nil && !isEncryptedPath(file.path) {
+            if err := l.resignError(file.path); err != nil {
+                l.reportRecovery(RecoveryEvent{Action: RecoveryLeftUnsigned, Path: file.path, Err: err})
+                continue
+            }
+            l.enqueueBackup(file.path)
+            l.reportRecovery(RecoveryEvent{Action: RecoveryEncrypted, Path: file.path})
+            continue
+        }
+        if l.config.SigningKeys == nil {
+            continue
+        }
+        journaled, err := l.checkJournal(file.path)
+        if !journaled {
+            if _, err := os.Stat(file.path + SignatureExt); os.IsNotExist(err) {
+                // Never signed here or its manifest was removed; VerifySignatures reports it
+                l.reportRecovery(RecoveryEvent{Action: RecoveryLeftUnsigned, Path: file.path, Err: ErrUnsigned})
+            }
+            continue
+        }
+        if err != nil {
+            // Changed since rotation, such as by restoring an old journal after an edit
+            l.reportRecovery(RecoveryEvent{Action: RecoveryLeftUnsigned, Path: file.path, Err: err})
+            continue
+        }
+        l.enqueueBackup(file.path)
+        l.reportRecovery(RecoveryEvent{Action: RecoverySigned, Path: file.path})
+    }
+    return nil
+}
+
+// resignError returns why recovery must not have a backup compressed or
+// encrypted, and so signed again, or nil if it may: the backup is in the
+// signing journal with the content it had at rotation, or its signature still
+// verifies. A new signature would launder any other backup, which may have
+// been tampered with.
+func (l *Logger) resignError(path string) error {
+    if l.config.SigningKeys == nil {
+        return nil
+    }
+    if journaled, err := l.checkJournal(path); journaled {
+        return err
+    }
+    return verifySignature(path, l.config.SigningKeys, l.config.EncryptionKeys).Err
+}
+
+// reportRecovery passes a recovery event to the configured callback
+func (l *Logger) reportRecovery(event RecoveryEvent) {
+    if l.config.OnRecovery != nil {
+        l.config.OnRecovery(event)
+    }
+}
+
+// needsValidation reports whether an archive other than the newest must be
+// validated on startup. Archives are published by a rename once complete, and
+// one with a signature manifest or a time index was written that way, so only
+// archives with neither, such as encrypted unsigned ones, are read in full.
+func needsValidation(path string, names map[string]bool) bool {
+    if names[filepath.Base(path)+SignatureExt] {
+        return false
+    }
+    f, err := os.Open(path)
+    if err != nil {
+        return true
+    }
+    defer f.Close()
+    _, err = readIndex(f)
+    return err != nil
+}
+
+// validateArchive reads a gzip file to the end, verifying its checksum and
+// length trailer and, if it is encrypted, its segments. Encrypted archives
+// whose key is not available are assumed valid.
+func validateArchive(path string, keys KeyProvider) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    var kerr *keyError
+    src, err := openPlaintext(f, keys)
+    if err == ErrEncrypted || errors.As(err, &kerr) {
+        return nil
+    }
+    if err != nil {
+        return err
+    }
+    zr, err := gzip.NewReader(src)
+    if err != nil {
+        return err
+    }
+    if _, err := io.Copy(io.Discard, zr); err != nil {
+        return err
+    }
+    return zr.Close()
+}
+
+// quarantineFile moves a file into the quarantine directory and returns its new path
+func quarantineFile(logDir, path string) (string, error) {
+    dir := filepath.Join(logDir, QuarantineDirName)
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return "", fmt.Errorf("failed to create quarantine directory: %v", err)
+    }
+    target := filepath.Join(dir, filepath.Base(path))
+    if err := os.Rename(path, target); err != nil {
+        return "", fmt.Errorf("failed to quarantine %s: %v", path, err)
+    }
+    // Keep the signature manifest with the archive for investigation
+    if err := os.Rename(path+SignatureExt, target+SignatureExt); err != nil && !os.IsNotExist(err) {
+        return "", fmt.Errorf("failed to quarantine %s: %v", path+SignatureExt, err)
+    }
+    return target, nil
+}
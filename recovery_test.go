@@ -0,0 +1,259 @@
+package logr
+
+import (
+    "bytes"
+    "compress/gzip"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+)
+
+func writeGzip(t *testing.T, path, content string) []byte {
+    var buf bytes.Buffer
+    zw := gzip.NewWriter(&buf)
+    zw.Write([]byte(content))
+    zw.Close()
+    if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+        t.Fatalf("failed to write %s: %v", path, err)
+    }
+    return buf.Bytes()
+}
+
+func TestStartupRecovery(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_recovery"
+    defer os.RemoveAll(tempDir)
+
+    if err := os.MkdirAll(tempDir, 0755); err != nil {
+        t.Fatalf("failed to create directory: %v", err)
+    }
+    backup := func(seq string) string {
+        return filepath.Join(tempDir, "rec_20240101_000000."+seq+".log")
+    }
+
+    // 1: a healthy archive
+    writeGzip(t, backup("1")+".gz", "healthy\n")
+    // 2: a truncated archive whose source survived
+    full := writeGzip(t, backup("2")+".gz", "source two\n")
+    os.WriteFile(backup("2")+".gz", full[:len(full)-4], 0644)
+    os.WriteFile(backup("2"), []byte("source two\n"), 0644)
+    // 3: a truncated archive without source
+    full = writeGzip(t, backup("3")+".gz", "lost three\n")
+    os.WriteFile(backup("3")+".gz", full[:len(full)-4], 0644)
+    // 4: a rotated file that was never compressed
+    os.WriteFile(backup("4"), []byte("orphan four\n"), 0644)
+    // 5: a partial temporary archive next to its source
+    os.WriteFile(backup("5"), []byte("source five\n"), 0644)
+    os.WriteFile(backup("5")+".gz.tmp", []byte{0x1f, 0x8b}, 0644)
+    // 6: a complete archive whose source was never removed
+    writeGzip(t, backup("6")+".gz", "done six\n")
+    os.WriteFile(backup("6"), []byte("done six\n"), 0644)
+
+    var mu sync.Mutex
+    events := map[string]RecoveryAction{}
+    config := &Config{
+        LogDir:     tempDir,
+        FileName:   "rec",
+        MaxSize:    1024 * 1024,
+        MaxAge:     time.Hour,
+        MaxBackups: 100,
+        Level:      INFO,
+        Compress:   true,
+        OnRecovery: func(event RecoveryEvent) {
+            mu.Lock()
+            defer mu.Unlock()
+            events[filepath.Base(event.Path)] = event.Action
+        },
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Close()
+
+    expectedEvents := map[string]RecoveryAction{
+        "rec_20240101_000000.2.log.gz":     RecoveryRecompressed,
+        "rec_20240101_000000.3.log.gz":     RecoveryQuarantined,
+        "rec_20240101_000000.4.log":        RecoveryCompressedOrphan,
+        "rec_20240101_000000.5.log.gz.tmp": RecoveryRemovedTemp,
+        "rec_20240101_000000.5.log":        RecoveryCompressedOrphan,
+        "rec_20240101_000000.6.log":        RecoveryRemovedDuplicate,
+    }
+    for name, action := range expectedEvents {
+        if events[name] != action {
+            t.Errorf("expected %s for %s, got %v", action, name, events[name])
+        }
+    }
+    if len(events) != len(expectedEvents) {
+        t.Errorf("expected %d recovery events, got %v", len(expectedEvents), events)
+    }
+
+    entries, err := os.ReadDir(tempDir)
+    if err != nil {
+        t.Fatalf("failed to read directory: %v", err)
+    }
+    var names []string
+    for _, entry := range entries {
+        names = append(names, entry.Name())
+        if strings.HasSuffix(entry.Name(), ".log.gz") {
+            if err := validateArchive(filepath.Join(tempDir, entry.Name()), nil); err != nil {
+                t.Errorf("archive %s is still invalid: %v", entry.Name(), err)
+            }
+        }
+    }
+    sort.Strings(names)
+
+    expected := []string{
+        QuarantineDirName,
+        "rec.log",
+        "rec_20240101_000000.1.log.gz",
+        "rec_20240101_000000.2.log.gz",
+        "rec_20240101_000000.4.log.gz",
+        "rec_20240101_000000.5.log.gz",
+        "rec_20240101_000000.6.log.gz",
+    }
+    if strings.Join(names, ",") != strings.Join(expected, ",") {
+        t.Errorf("unexpected directory contents after recovery:\n got: %v\nwant: %v", names, expected)
+    }
+    if _, err := os.Stat(filepath.Join(tempDir, QuarantineDirName, "rec_20240101_000000.3.log.gz")); err != nil {
+        t.Errorf("expected the corrupted archive in quarantine: %v", err)
+    }
+}
+
+func TestStartupRecoveryValidatesRecentArchives(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_recovery_recent"
+    defer os.RemoveAll(tempDir)
+
+    if err := os.MkdirAll(tempDir, 0755); err != nil {
+        t.Fatalf("failed to create directory: %v", err)
+    }
+    backup := func(seq string) string {
+        return filepath.Join(tempDir, "rec_20240101_000000."+seq+".log")
+    }
+    indexed := func(seq string) {
+        os.WriteFile(backup(seq), []byte("[2024-01-01 00:00:00.000] [INFO] record "+seq+"\n"), 0644)
+        if err := compressFile(backup(seq), backup(seq)+".gz", nil); err != nil {
+            t.Fatalf("failed to compress: %v", err)
+        }
+    }
+    truncate := func(path string) {
+        data, _ := os.ReadFile(path)
+        os.WriteFile(path, data[:len(data)-4], 0644)
+    }
+
+    // 1: an indexed archive, published complete and not read on startup
+    indexed("1")
+    truncate(backup("1") + ".gz")
+    // 2: an archive without index
+    full := writeGzip(t, backup("2")+".gz", "plain two\n")
+    os.WriteFile(backup("2")+".gz", full[:len(full)-4], 0644)
+    // 3: the newest archive
+    indexed("3")
+    truncate(backup("3") + ".gz")
+
+    events := map[string]RecoveryAction{}
+    logger, err := NewLogger(&Config{
+        LogDir:     tempDir,
+        FileName:   "rec",
+        MaxSize:    1024 * 1024,
+        MaxBackups: 100,
+        Level:      INFO,
+        Compress:   true,
+        OnRecovery: func(event RecoveryEvent) {
+            events[filepath.Base(event.Path)] = event.Action
+        },
+    })
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Close()
+
+    expected := map[string]RecoveryAction{
+        "rec_20240101_000000.2.log.gz": RecoveryQuarantined,
+        "rec_20240101_000000.3.log.gz": RecoveryQuarantined,
+    }
+    if len(events) != len(expected) {
+        t.Errorf("expected %d recovery events, got %v", len(expected), events)
+    }
+    for name, action := range expected {
+        if events[name] != action {
+            t.Errorf("expected %s for %s, got %v", action, name, events[name])
+        }
+    }
+}
+
+func TestStartupRecoveryIgnoresOtherLoggers(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_recovery_shared"
+    defer os.RemoveAll(tempDir)
+
+    audit, err := NewLogger(&Config{
+        LogDir:     tempDir,
+        FileName:   "app_audit",
+        MaxSize:    1024 * 1024,
+        MaxBackups: 100,
+        Level:      INFO,
+    })
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer audit.Close()
+    audit.Info("before")
+    audit.Sync()
+
+    // Files of app_audit that look like backups of app to a loose match
+    other := []string{
+        "app_audit_20240101_000000.1.log",
+        "app_audit_20240101_000000.2.log.gz.tmp",
+        "app_notes.log",
+    }
+    for _, name := range other {
+        os.WriteFile(filepath.Join(tempDir, name), []byte("other logger\n"), 0644)
+    }
+
+    var mu sync.Mutex
+    var events []RecoveryEvent
+    logger, err := NewLogger(&Config{
+        LogDir:     tempDir,
+        FileName:   "app",
+        MaxSize:    1024 * 1024,
+        MaxBackups: 100,
+        Level:      INFO,
+        Compress:   true,
+        OnRecovery: func(event RecoveryEvent) {
+            mu.Lock()
+            defer mu.Unlock()
+            events = append(events, event)
+        },
+    })
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Close()
+
+    if len(events) != 0 {
+        t.Errorf("expected no recovery events, got %v", events)
+    }
+    for _, name := range append(other, "app_audit.log") {
+        if _, err := os.Stat(filepath.Join(tempDir, name)); err != nil {
+            t.Errorf("recovery touched %s of another logger: %v", name, err)
+        }
+    }
+
+    // The other logger must still be writing to its visible active file
+    audit.Info("after")
+    audit.Sync()
+    content, err := os.ReadFile(filepath.Join(tempDir, "app_audit.log"))
+    if err != nil {
+        t.Fatalf("failed to read active file: %v", err)
+    }
+    if !strings.Contains(string(content), "before") || !strings.Contains(string(content), "after") {
+        t.Errorf("records of the other logger were lost: %q", content)
+    }
+}
@@ -0,0 +1,239 @@
+package logr
+
+import (
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "regexp"
+    "strings"
+    "sync/atomic"
+)
+
+// RedactMode selects how sensitive values are replaced
+type RedactMode int
+
+const (
+    RedactFull    RedactMode = iota // Replace the value with [REDACTED]
+    RedactPartial                   // Mask all but the last 4 characters, e.g. ************1111
+    RedactHash                      // Replace the value with a keyed hash, so equal values can be correlated
+)
+
+// redactedText replaces values in RedactFull mode
+const redactedText = "[REDACTED]"
+
+var errRedactHashKey = errors.New("RedactHash requires Redaction.HashKey")
+
+// Redaction configures the masking of sensitive data in messages and fields
+// before records are encoded
+type Redaction struct {
+    Mode       RedactMode   // How values are replaced
+    HashKey    []byte       // HMAC-SHA256 key of RedactHash mode
+    Rules      []RedactRule // Rules applied after the built-in detectors
+    FieldNames []string     // Fields whose whole value is masked, matched case-insensitively
+    NoDefaults bool         // Disable the built-in detectors and field names
+}
+
+// RedactRule masks the matches of a pattern in messages and field values. If
+// the pattern has capture groups, only the text of the groups that matched is
+// masked, a group nested in another as part of it; otherwise the whole match
+// is.
+type RedactRule struct {
+    Name    string
+    Pattern *regexp.Regexp
+    Valid   func(match string) bool // If set, only matches it accepts are masked
+}
+
+// DefaultRedactRules are the built-in detectors: passwords in key=value pairs
+// and SQL, bearer tokens, payment card numbers passing the Luhn check and
+// email addresses
+var DefaultRedactRules = []RedactRule{
+    {
+        Name:    "password",
+        Pattern: regexp.MustCompile(`(?i)(?:\b(?:password|passwd|pwd|secret)\s*[=:]\s*|\bidentified\s+by\s+)(?:'([^']*)'|"([^"]*)"|([^\s,;)'"]+))`),
+    },
+    {
+        Name:    "bearer",
+        Pattern: regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+    },
+    {
+        Name:    "pan",
+        Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+        Valid:   luhnValid,
+    },
+    {
+        Name:    "email",
+        Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}\b`),
+    },
+}
+
+// DefaultRedactFields are the built-in names of fields masked entirely
+var DefaultRedactFields = []string{
+    "password", "passwd", "pwd", "secret", "token", "access_token", "refresh_token",
+    "api_key", "apikey", "authorization", "cookie",
+}
+
+// redactor applies a Redaction to entries
+type redactor struct {
+    mode    RedactMode
+    hashKey []byte
+    rules   []RedactRule
+    fields  map[string]bool
+    count   *uint64 // Redactions applied, updated atomically
+}
+
+// newRedactor validates a redaction configuration and prepares it
+func newRedactor(config *Redaction, count *uint64) (*redactor, error) {
+    if config.Mode == RedactHash && len(config.HashKey) == 0 {
+        return nil, errRedactHashKey
+    }
+    r := &redactor{
+        mode:    config.Mode,
+        hashKey: config.HashKey,
+        fields:  make(map[string]bool),
+        count:   count,
+    }
+    if !config.NoDefaults {
+        r.rules = append(r.rules, DefaultRedactRules...)
+        for _, name := range DefaultRedactFields {
+            r.fields[name] = true
+        }
+    }
+    r.rules = append(r.rules, config.Rules...)
+    for _, name := range config.FieldNames {
+        r.fields[strings.ToLower(name)] = true
+    }
+    return r, nil
+}
+
+// redactEntry masks the sensitive data of an entry in place. The fields slice
+// is copied before it is changed, as it may be shared with the caller.
+func (r *redactor) redactEntry(e *Entry) {
+    e.Message = r.redactString(e.Message)
+    e.Fields = r.redactFields(e.Fields)
+}
+
+// redactFields masks the sensitive data of fields, including those nested in
+// groups. It returns a copy if any field changed, or fields itself otherwise.
+func (r *redactor) redactFields(fields []Field) []Field {
+    copied := false
+    for i, f := range fields {
+        var masked Field
+        switch {
+        case r.fields[strings.ToLower(f.Key)]:
+            masked = String(f.Key, r.mask(f.ValueString()))
+            atomic.AddUint64(r.count, 1)
+        case f.Type == GroupType:
+            group := f.Interface.([]Field)
+            nested := r.redactFields(group)
+            if len(nested) == 0 || &nested[0] == &group[0] {
+                continue
+            }
+            masked = Group(f.Key, nested...)
+        case f.Type == StringType || f.Type == ErrorType || f.Type == AnyType || f.Type == Int64Type:
+            // Integers too, as card and account numbers are often stored as int64
+            original := f.ValueString()
+            value := r.redactString(original)
+            if value == original {
+                continue
+            }
+            masked = String(f.Key, value)
+        default:
+            continue
+        }
+        if !copied {
+            fields = append([]Field(nil), fields...)
+            copied = true
+        }
+        fields[i] = masked
+    }
+    return fields
+}
+
+// redactString masks the matches of every rule in s
+func (r *redactor) redactString(s string) string {
+    for _, rule := range r.rules {
+        s = r.applyRule(rule, s)
+    }
+    return s
+}
+
+// applyRule masks the matches of one rule in s
+func (r *redactor) applyRule(rule RedactRule, s string) string {
+    matches := rule.Pattern.FindAllStringSubmatchIndex(s, -1)
+    if matches == nil {
+        return s
+    }
+
+    var sb strings.Builder
+    last := 0
+    for _, m := range matches {
+        if rule.Valid != nil && !rule.Valid(s[m[0]:m[1]]) {
+            continue
+        }
+        spans := [][]int{{m[0], m[1]}}
+        if len(m) > 2 {
+            spans = spans[:0]
+            for g := 2; g < len(m); g += 2 {
+                if m[g] >= 0 {
+                    spans = append(spans, []int{m[g], m[g+1]})
+                }
+            }
+        }
+        for _, span := range spans {
+            // Groups are ordered by their opening parenthesis, so a nested
+            // group follows the group containing it; mask the outermost only
+            if span[0] < last {
+                continue
+            }
+            sb.WriteString(s[last:span[0]])
+            sb.WriteString(r.mask(s[span[0]:span[1]]))
+            last = span[1]
+            atomic.AddUint64(r.count, 1)
+        }
+    }
+    if last == 0 {
+        return s
+    }
+    sb.WriteString(s[last:])
+    return sb.String()
+}
+
+// mask replaces a sensitive value according to the mode
+func (r *redactor) mask(value string) string {
+    switch r.mode {
+    case RedactPartial:
+        runes := []rune(value)
+        keep := 4
+        if len(runes) <= 2*keep {
+            keep = 0
+        }
+        return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+    case RedactHash:
+        h := hmac.New(sha256.New, r.hashKey)
+        h.Write([]byte(value))
+        return "[hmac:" + hex.EncodeToString(h.Sum(nil)[:8]) + "]"
+    default:
+        return redactedText
+    }
+}
+
+// luhnValid reports whether the digits of s pass the Luhn checksum
+func luhnValid(s string) bool {
+    sum, n := 0, 0
+    for i := len(s) - 1; i >= 0; i-- {
+        c := s[i]
+        if c == ' ' || c == '-' {
+            continue
+        }
+        d := int(c - '0')
+        if n%2 == 1 {
+            if d *= 2; d > 9 {
+                d -= 9
+            }
+        }
+        sum += d
+        n++
+    }
+    return n >= 13 && sum%10 == 0
+}
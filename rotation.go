@@ -0,0 +1,113 @@
+package logr
+
+import (
+    "fmt"
+    "time"
+)
+
+const day = 24 * time.Hour
+
+// nextRotation returns the first rotation boundary strictly after now.
+// Intervals that divide a day are aligned to wall-clock multiples of the
+// interval since midnight, multiples of a day fall on midnight, and any
+// other interval is aligned to the Unix epoch.
+func nextRotation(now time.Time, interval time.Duration, loc *time.Location) time.Time {
+    if loc == nil {
+        loc = time.Local
+    }
+    t := now.In(loc)
+    y, m, d := t.Date()
+    nextMidnight := time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+
+    switch {
+    case interval%day == 0:
+        // Count civil days so multi-day periods stay stable across restarts
+        days := int64(interval / day)
+        civil := time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+        return time.Date(y, m, d+int(days-civil%days), 0, 0, 0, 0, loc)
+    case day%interval == 0:
+        // Wall-clock seconds since midnight, rounded up to the next multiple
+        wall := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+            time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+        k := wall/interval + 1
+        next := wallClockTime(y, m, d, k*interval, loc)
+        // Wall-clock times repeated by a DST change can land before now
+        for !next.After(now) {
+            k++
+            next = wallClockTime(y, m, d, k*interval, loc)
+        }
+        if next.After(nextMidnight) {
+            return nextMidnight
+        }
+        return next
+    default:
+        return now.Truncate(interval).Add(interval)
+    }
+}
+
+// wallClockTime returns the time at the given wall-clock offset since the
+// midnight starting a day. The offset is split into hours, minutes, seconds
+// and nanoseconds, as its nanoseconds overflow an int on 32-bit platforms.
+func wallClockTime(y int, m time.Month, d int, offset time.Duration, loc *time.Location) time.Time {
+    return time.Date(y, m, d, int(offset/time.Hour), int(offset%time.Hour/time.Minute),
+        int(offset%time.Minute/time.Second), int(offset%time.Second), loc)
+}
+
+// nextRotationTime returns the next time-based rotation boundary, or the zero time when disabled
+func (l *Logger) nextRotationTime(now time.Time) time.Time {
+    if l.config.RotateInterval <= 0 {
+        return time.Time{}
+    }
+    return nextRotation(now, l.config.RotateInterval, l.config.RotateLocation)
+}
+
+// periodExpired reports whether the active file's rotation period has ended
+func (l *Logger) periodExpired(now time.Time) bool {
+    return !l.periodEnd.IsZero() && !now.Before(l.periodEnd)
+}
+
+// rotateRoutine is the goroutine that rotates the log file at clock boundaries,
+// even when no record arrives at the boundary
+func (l *Logger) rotateRoutine() {
+    for {
+        l.mu.Lock()
+        next := l.periodEnd
+        l.mu.Unlock()
+        if next.IsZero() {
+            next = l.nextRotationTime(time.Now())
+        }
+
+        timer := time.NewTimer(time.Until(next))
+        select {
+        case <-timer.C:
+            l.rotateExpiredPeriod()
+        case <-l.stopChan:
+            timer.Stop()
+            return
+        }
+    }
+}
+
+// rotateExpiredPeriod rotates the log file if its period has ended
+func (l *Logger) rotateExpiredPeriod() {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    now := time.Now()
+    if l.file == nil || !l.periodExpired(now) {
+        return
+    }
+
+    // An empty file is kept for the new period instead of producing an empty backup
+    if l.currentSize == 0 {
+        l.periodEnd = l.nextRotationTime(now)
+        return
+    }
+    if err := l.rotateFile(); err != nil {
+        fmt.Fprintf(diagnostics, "log rotation failed: %v\n", err)
+        // Retry at the next boundary rather than immediately
+        if l.periodExpired(now) {
+            l.periodEnd = l.nextRotationTime(now)
+        }
+    }
+}
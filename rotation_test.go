@@ -0,0 +1,146 @@
+package logr
+
+import (
+    "os"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestNextRotation(t *testing.T) {
+    shanghai := time.FixedZone("CST", 8*3600)
+    newYork, err := time.LoadLocation("America/New_York")
+    if err != nil {
+        t.Skipf("time zone database unavailable: %v", err)
+    }
+
+    cases := []struct {
+        name     string
+        now      time.Time
+        interval time.Duration
+        loc      *time.Location
+        expected time.Time
+    }{
+        {
+            "hourly",
+            time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC),
+            time.Hour, time.UTC,
+            time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+        },
+        {
+            "hourly on boundary",
+            time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+            time.Hour, time.UTC,
+            time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+        },
+        {
+            "daily in time zone",
+            time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC), // 04:00 on March 2nd in CST
+            24 * time.Hour, shanghai,
+            time.Date(2024, 3, 3, 0, 0, 0, 0, shanghai),
+        },
+        {
+            "fifteen minutes",
+            time.Date(2024, 3, 1, 23, 50, 0, 0, time.UTC),
+            15 * time.Minute, time.UTC,
+            time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+        },
+        {
+            "hourly across spring forward",
+            time.Date(2024, 3, 10, 1, 30, 0, 0, newYork),
+            time.Hour, newYork,
+            time.Date(2024, 3, 10, 3, 0, 0, 0, newYork),
+        },
+        {
+            "daily across fall back",
+            time.Date(2024, 11, 3, 12, 0, 0, 0, newYork),
+            24 * time.Hour, newYork,
+            time.Date(2024, 11, 4, 0, 0, 0, 0, newYork),
+        },
+        {
+            "hourly late in the day",
+            time.Date(2024, 3, 1, 22, 59, 59, 0, time.UTC),
+            time.Hour, time.UTC,
+            time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC),
+        },
+        {
+            "ninety minutes",
+            time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC),
+            90 * time.Minute, time.UTC,
+            time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+        },
+    }
+
+    for _, tc := range cases {
+        got := nextRotation(tc.now, tc.interval, tc.loc)
+        if !got.Equal(tc.expected) {
+            t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, got)
+        }
+    }
+}
+
+// Offsets past 2^31 nanoseconds (about 2.1s) must not overflow an int on
+// 32-bit platforms
+func TestWallClockTime(t *testing.T) {
+    offsets := []time.Duration{
+        1500 * time.Millisecond,
+        3 * time.Second,
+        time.Hour,
+        23*time.Hour + 59*time.Minute + 59*time.Second + 999*time.Millisecond,
+    }
+    midnight := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+    for _, offset := range offsets {
+        if got := wallClockTime(2024, 3, 1, offset, time.UTC); !got.Equal(midnight.Add(offset)) {
+            t.Errorf("offset %v: expected %v, got %v", offset, midnight.Add(offset), got)
+        }
+    }
+}
+
+func TestTimeRotationWithoutWrites(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_time_rotation"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:         tempDir,
+        FileName:       "time_test",
+        MaxSize:        1024 * 1024,
+        MaxAge:         time.Hour,
+        MaxBackups:     10,
+        Level:          INFO,
+        RotateInterval: time.Second,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    logger.Info("record in the first period")
+
+    // No further writes: the boundary alone must close the period's file
+    time.Sleep(1500 * time.Millisecond)
+
+    files, err := os.ReadDir(tempDir)
+    if err != nil {
+        t.Fatalf("failed to read directory: %v", err)
+    }
+    backups := 0
+    for _, file := range files {
+        if strings.HasPrefix(file.Name(), "time_test_") {
+            backups++
+        }
+    }
+    if backups != 1 {
+        t.Errorf("expected 1 backup after the boundary, got %d", backups)
+    }
+
+    info, err := os.Stat(tempDir + "/time_test.log")
+    if err != nil {
+        t.Fatalf("active log file missing: %v", err)
+    }
+    if info.Size() != 0 {
+        t.Errorf("expected an empty active file for the new period, got %d bytes", info.Size())
+    }
+}
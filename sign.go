@@ -0,0 +1,479 @@
+package logr
+
+import (
+    "compress/gzip"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// SignatureExt is appended to a backup's name for its detached signature manifest
+const SignatureExt = ".sig"
+
+// signJournalExt is appended to the log file name for the journal of backups
+// waiting to be signed
+const signJournalExt = ".unsigned"
+
+// signatureAlgorithm identifies how manifests are authenticated
+const signatureAlgorithm = "HMAC-SHA256"
+
+// Signature verification failures reported in SignatureResult.Err
+var (
+    ErrUnsigned         = errors.New("backup has no signature manifest")
+    ErrUnknownKey       = errors.New("signature key ID is not in the keyring")
+    ErrBadSignature     = errors.New("signature does not match the manifest")
+    ErrManifestMismatch = errors.New("backup does not match its signature manifest")
+    ErrMissingBackup    = errors.New("signature manifest without backup")
+)
+
+var errInvalidSigningKey = errors.New("signing keyring has no key for its active key ID")
+
+// Keyring holds HMAC keys by key ID. New backups are signed with the active
+// key; older keys are kept so backups signed before a key rotation still verify.
+type Keyring struct {
+    ActiveKeyID string
+    Keys        map[string][]byte
+}
+
+// SignatureManifest is the detached manifest written next to a signed backup
+type SignatureManifest struct {
+    File      string    `json:"file"`
+    Size      int64     `json:"size"`
+    SHA256    string    `json:"sha256"`
+    First     time.Time `json:"first"`
+    Last      time.Time `json:"last"`
+    Records   int       `json:"records"`
+    KeyID     string    `json:"key_id"`
+    Algorithm string    `json:"alg"`
+    MAC       string    `json:"mac"`
+}
+
+// SignatureResult is the verification outcome for one backup
+type SignatureResult struct {
+    Path     string
+    Manifest *SignatureManifest // nil if the manifest is missing or unreadable
+    Err      error              // nil if the backup is authentic
+}
+
+// validate checks that the keyring can sign
+func (k *Keyring) validate() error {
+    if len(k.Keys[k.ActiveKeyID]) == 0 {
+        return errInvalidSigningKey
+    }
+    return nil
+}
+
+// mac computes the manifest's authentication code with key
+func (m *SignatureManifest) mac(key []byte) []byte {
+    h := hmac.New(sha256.New, key)
+    fmt.Fprintf(h, "logr-signature-v1\n%s\n%d\n%s\n%s\n%s\n%d\n%s\n%s\n",
+        m.File, m.Size, m.SHA256,
+        m.First.UTC().Format(time.RFC3339Nano), m.Last.UTC().Format(time.RFC3339Nano),
+        m.Records, m.KeyID, m.Algorithm)
+    return h.Sum(nil)
+}
+
+// signFile writes a signature manifest for a finished backup, decrypting it
+// with decrypt to count its records if it is encrypted
+func signFile(path string, keys *Keyring, decrypt KeyProvider) error {
+    manifest, err := describeBackup(path, decrypt)
+    if err != nil {
+        return err
+    }
+    manifest.KeyID = keys.ActiveKeyID
+    manifest.Algorithm = signatureAlgorithm
+    manifest.MAC = hex.EncodeToString(manifest.mac(keys.Keys[keys.ActiveKeyID]))
+
+    data, err := json.MarshalIndent(manifest, "", "  ")
+    if err != nil {
+        return err
+    }
+
+    // Publish the manifest atomically, like archives
+    tmpPath := path + SignatureExt + ".tmp"
+    if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to write signature manifest: %v", err)
+    }
+    if err := os.Rename(tmpPath, path+SignatureExt); err != nil {
+        os.Remove(tmpPath)
+        return fmt.Errorf("failed to rename signature manifest: %v", err)
+    }
+    return nil
+}
+
+// describeBackup computes the manifest fields of a backup, except the signature
+func describeBackup(path string, decrypt KeyProvider) (*SignatureManifest, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    h := sha256.New()
+    size, err := io.Copy(h, f)
+    if err != nil {
+        return nil, fmt.Errorf("failed to hash %s: %v", path, err)
+    }
+    manifest := &SignatureManifest{
+        File:   filepath.Base(path),
+        Size:   size,
+        SHA256: hex.EncodeToString(h.Sum(nil)),
+    }
+
+    reader := &Reader{keys: decrypt}
+    if _, err := f.Seek(0, io.SeekStart); err != nil {
+        return nil, err
+    }
+    if err := reader.openFile(f, logFile{name: manifest.File, path: path}); err != nil {
+        return nil, err
+    }
+    for {
+        record, err := reader.Next()
+        if err == io.EOF {
+            break
+        }
+        if _, ok := err.(*ParseError); ok {
+            continue
+        }
+        if err != nil {
+            return nil, err
+        }
+        if manifest.Records == 0 {
+            manifest.First = record.Time
+        }
+        if record.Time.After(manifest.Last) {
+            manifest.Last = record.Time
+        }
+        manifest.Records++
+    }
+    return manifest, nil
+}
+
+// readManifest reads the signature manifest of a backup
+func readManifest(path string) (*SignatureManifest, error) {
+    data, err := os.ReadFile(path + SignatureExt)
+    if err != nil {
+        return nil, err
+    }
+    manifest := &SignatureManifest{}
+    if err := json.Unmarshal(data, manifest); err != nil {
+        return nil, fmt.Errorf("invalid signature manifest: %v", err)
+    }
+    return manifest, nil
+}
+
+// verifySignature checks a backup against its manifest and the keyring
+func verifySignature(path string, keys *Keyring, decrypt KeyProvider) SignatureResult {
+    result := SignatureResult{Path: path}
+    manifest, err := readManifest(path)
+    if os.IsNotExist(err) {
+        result.Err = ErrUnsigned
+        return result
+    }
+    if err != nil {
+        result.Err = err
+        return result
+    }
+    result.Manifest = manifest
+
+    key, ok := keys.Keys[manifest.KeyID]
+    if !ok {
+        result.Err = ErrUnknownKey
+        return result
+    }
+    mac, err := hex.DecodeString(manifest.MAC)
+    if err != nil || manifest.Algorithm != signatureAlgorithm || !hmac.Equal(mac, manifest.mac(key)) {
+        result.Err = ErrBadSignature
+        return result
+    }
+
+    actual, err := describeBackup(path, decrypt)
+    if err != nil {
+        result.Err = err
+        return result
+    }
+    if actual.File != manifest.File || actual.Size != manifest.Size || actual.SHA256 != manifest.SHA256 ||
+        actual.Records != manifest.Records || !actual.First.Equal(manifest.First) || !actual.Last.Equal(manifest.Last) {
+        result.Err = ErrManifestMismatch
+    }
+    return result
+}
+
+// VerifySignatures checks every backup of a logger configuration against its
+// signature manifest and the keyring. It returns one result per backup, plus
+// one per manifest whose backup no longer exists. Backups that are still
+// being compressed or signed are reported as unsigned. Encrypted backups are
+// decrypted with config.EncryptionKeys to check their record counts.
+func VerifySignatures(config *Config, keys *Keyring) ([]SignatureResult, error) {
+    namer, err := newBackupNamer(config.FileName, config.BackupNameTemplate)
+    if err != nil {
+        return nil, err
+    }
+    files, err := listLogFiles(config.LogDir, namer)
+    if err != nil {
+        return nil, fmt.Errorf("failed to list log files: %v", err)
+    }
+
+    var results []SignatureResult
+    backups := make(map[string]bool, len(files))
+    for _, file := range files {
+        if file.active {
+            continue
+        }
+        backups[file.name] = true
+        results = append(results, verifySignature(file.path, keys, config.EncryptionKeys))
+    }
+
+    // Manifests left behind by deleted backups
+    entries, err := os.ReadDir(config.LogDir)
+    if err != nil {
+        return nil, fmt.Errorf("failed to read log directory: %v", err)
+    }
+    for _, entry := range entries {
+        name := strings.TrimSuffix(entry.Name(), SignatureExt)
+        if entry.IsDir() || name == entry.Name() || backups[name] {
+            continue
+        }
+        if _, ok := namer.parse(name); !ok {
+            continue
+        }
+        result := SignatureResult{Path: filepath.Join(config.LogDir, name), Err: ErrMissingBackup}
+        result.Manifest, _ = readManifest(result.Path)
+        results = append(results, result)
+    }
+    return results, nil
+}
+
+// signJournal lists the backups handed to the workers for signing and not
+// signed yet, so that recovery resumes signing interrupted by a crash but
+// never signs a backup whose manifest was removed. Each backup is listed with
+// the hash of its content at rotation, so that restoring an old journal cannot
+// have a backup edited since signed again. The journal is authenticated like
+// manifests, as forging it would have a backup signed.
+type signJournal struct {
+    Files []journalEntry `json:"files"`
+    KeyID string         `json:"key_id"`
+    MAC   string         `json:"mac"`
+}
+
+// journalEntry is a backup waiting to be signed
+type journalEntry struct {
+    File   string `json:"file"`
+    SHA256 string `json:"sha256"` // Hash of the log content, empty if it could not be read
+}
+
+// errJournalMismatch reports a journaled backup changed since its rotation
+var errJournalMismatch = errors.New("backup does not match the signing journal")
+
+// mac computes the journal's authentication code with key
+func (j *signJournal) mac(key []byte) []byte {
+    h := hmac.New(sha256.New, key)
+    fmt.Fprintf(h, "logr-sign-journal-v2\n%s\n", j.KeyID)
+    for _, entry := range j.Files {
+        fmt.Fprintf(h, "%s\n%s\n", entry.File, entry.SHA256)
+    }
+    return h.Sum(nil)
+}
+
+// contentHash returns the SHA-256 of a log file's content, which compression
+// and encryption do not change
+func contentHash(path string, keys KeyProvider) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    src, err := openPlaintext(f, keys)
+    if err != nil {
+        return "", err
+    }
+    if strings.HasSuffix(path, ".gz") {
+        zr, err := gzip.NewReader(src)
+        if err != nil {
+            return "", err
+        }
+        src = zr
+    }
+    h := sha256.New()
+    if _, err := io.Copy(h, src); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// journalPath returns the path of the signing journal
+func (l *Logger) journalPath() string {
+    return filepath.Join(l.config.LogDir, l.config.FileName+signJournalExt)
+}
+
+// loadJournal reads the signing journal left by the previous run. A journal
+// that does not verify is ignored, leaving its backups unsigned.
+func (l *Logger) loadJournal() {
+    l.unsigned = make(map[string]string)
+    data, err := os.ReadFile(l.journalPath())
+    if os.IsNotExist(err) {
+        return
+    }
+    journal := &signJournal{}
+    if err == nil {
+        err = json.Unmarshal(data, journal)
+    }
+    if err == nil {
+        key, ok := l.config.SigningKeys.Keys[journal.KeyID]
+        mac, herr := hex.DecodeString(journal.MAC)
+        if !ok || herr != nil || !hmac.Equal(mac, journal.mac(key)) {
+            err = errors.New("signature does not match the journal")
+        }
+    }
+    if err != nil {
+        fmt.Fprintf(diagnostics, "Warning: ignoring signing journal %s: %v\n", l.journalPath(), err)
+        return
+    }
+    for _, entry := range journal.Files {
+        l.unsigned[entry.File] = entry.SHA256
+    }
+}
+
+// journalBackup adds a backup to the signing journal before it is handed to
+// the workers, with the hash of its content read from src
+func (l *Logger) journalBackup(path, src string) {
+    if l.unsigned == nil {
+        return
+    }
+    name := strings.TrimSuffix(filepath.Base(path), ".gz")
+    l.journalMu.Lock()
+    defer l.journalMu.Unlock()
+    if _, ok := l.unsigned[name]; ok {
+        return
+    }
+    hash, err := contentHash(src, l.config.EncryptionKeys)
+    if err != nil {
+        // Recovery never signs it again, but the workers still sign it
+        fmt.Fprintf(diagnostics, "failed to hash %s for the signing journal: %v\n", src, err)
+    }
+    l.unsigned[name] = hash
+    if err := l.writeJournal(); err != nil {
+        fmt.Fprintf(diagnostics, "failed to write signing journal: %v\n", err)
+    }
+}
+
+// unjournalBackup removes a backup from the signing journal once it is signed
+// or gone
+func (l *Logger) unjournalBackup(path string) {
+    if l.unsigned == nil {
+        return
+    }
+    name := strings.TrimSuffix(filepath.Base(path), ".gz")
+    l.journalMu.Lock()
+    defer l.journalMu.Unlock()
+    if _, ok := l.unsigned[name]; !ok {
+        return
+    }
+    delete(l.unsigned, name)
+    if err := l.writeJournal(); err != nil {
+        fmt.Fprintf(diagnostics, "failed to write signing journal: %v\n", err)
+    }
+}
+
+// checkJournal reports whether a backup is in the signing journal, and if so
+// returns errJournalMismatch unless its content is unchanged since rotation
+func (l *Logger) checkJournal(path string) (bool, error) {
+    l.journalMu.Lock()
+    hash, ok := l.unsigned[strings.TrimSuffix(filepath.Base(path), ".gz")]
+    l.journalMu.Unlock()
+    if !ok {
+        return false, nil
+    }
+    actual, err := contentHash(path, l.config.EncryptionKeys)
+    if err != nil {
+        return true, err
+    }
+    if hash == "" || actual != hash {
+        return true, errJournalMismatch
+    }
+    return true, nil
+}
+
+// pruneJournal forgets the backups of the signing journal that no longer
+// exist or whose signature verifies, as a crash may stop a worker between
+// signing a backup and updating the journal
+func (l *Logger) pruneJournal() {
+    l.journalMu.Lock()
+    defer l.journalMu.Unlock()
+    pruned := false
+    for name := range l.unsigned {
+        path := filepath.Join(l.config.LogDir, name)
+        if _, err := os.Stat(path); os.IsNotExist(err) {
+            path += ".gz"
+        }
+        if _, err := os.Stat(path); os.IsNotExist(err) ||
+            verifySignature(path, l.config.SigningKeys, l.config.EncryptionKeys).Err == nil {
+            delete(l.unsigned, name)
+            pruned = true
+        }
+    }
+    if !pruned {
+        return
+    }
+    if err := l.writeJournal(); err != nil {
+        fmt.Fprintf(diagnostics, "failed to write signing journal: %v\n", err)
+    }
+}
+
+// writeJournal publishes the signing journal atomically, or removes it when
+// no backup is pending. journalMu must be held.
+func (l *Logger) writeJournal() error {
+    path := l.journalPath()
+    if len(l.unsigned) == 0 {
+        if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+            return err
+        }
+        return nil
+    }
+
+    journal := &signJournal{KeyID: l.config.SigningKeys.ActiveKeyID}
+    for name, hash := range l.unsigned {
+        journal.Files = append(journal.Files, journalEntry{File: name, SHA256: hash})
+    }
+    sort.Slice(journal.Files, func(i, j int) bool {
+        return journal.Files[i].File < journal.Files[j].File
+    })
+    journal.MAC = hex.EncodeToString(journal.mac(l.config.SigningKeys.Keys[journal.KeyID]))
+    data, err := json.MarshalIndent(journal, "", "  ")
+    if err != nil {
+        return err
+    }
+
+    tmpPath := path + ".tmp"
+    if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+        os.Remove(tmpPath)
+        return err
+    }
+    if err := os.Rename(tmpPath, path); err != nil {
+        os.Remove(tmpPath)
+        return err
+    }
+    return nil
+}
+
+// removeBackup deletes a backup together with its signature manifest
+func removeBackup(path string) error {
+    if err := os.Remove(path); err != nil {
+        return err
+    }
+    if err := os.Remove(path + SignatureExt); err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    return nil
+}
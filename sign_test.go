@@ -0,0 +1,222 @@
+package logr
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestBackupSignatures(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_signatures"
+    defer os.RemoveAll(tempDir)
+
+    keys := &Keyring{
+        ActiveKeyID: "2024-01",
+        Keys:        map[string][]byte{"2024-01": []byte("first secret key")},
+    }
+    config := &Config{
+        LogDir:      tempDir,
+        FileName:    "signed",
+        MaxSize:     300,
+        MaxAge:      time.Hour,
+        MaxBackups:  100,
+        Level:       DEBUG,
+        Compress:    true,
+        SigningKeys: keys,
+    }
+
+    writeBackups := func(compress bool) {
+        config.Compress = compress
+        logger, err := NewLogger(config)
+        if err != nil {
+            t.Fatalf("failed to create logger: %v", err)
+        }
+        for i := 0; i < 20; i++ {
+            logger.Info("signed record %d", i)
+        }
+        logger.Close()
+    }
+
+    // Sign with the first key, then rotate keys and sign plain backups with the second
+    writeBackups(true)
+    keys.Keys["2024-02"] = []byte("second secret key")
+    keys.ActiveKeyID = "2024-02"
+    writeBackups(false)
+
+    results, err := VerifySignatures(config, keys)
+    if err != nil {
+        t.Fatalf("failed to verify signatures: %v", err)
+    }
+    usedKeys := map[string]int{}
+    for _, result := range results {
+        if result.Err != nil {
+            t.Errorf("%s: %v", result.Path, result.Err)
+            continue
+        }
+        usedKeys[result.Manifest.KeyID]++
+        if result.Manifest.Records == 0 || result.Manifest.First.IsZero() {
+            t.Errorf("%s: incomplete manifest %+v", result.Path, result.Manifest)
+        }
+    }
+    if usedKeys["2024-01"] == 0 || usedKeys["2024-02"] == 0 {
+        t.Fatalf("expected backups signed with both keys, got %v", usedKeys)
+    }
+
+    // Without the retired key its backups cannot be verified
+    results, _ = VerifySignatures(config, &Keyring{Keys: map[string][]byte{"2024-02": keys.Keys["2024-02"]}})
+    unknown := 0
+    for _, result := range results {
+        if errors.Is(result.Err, ErrUnknownKey) {
+            unknown++
+        }
+    }
+    if unknown != usedKeys["2024-01"] {
+        t.Errorf("expected %d backups with an unknown key, got %d", usedKeys["2024-01"], unknown)
+    }
+
+    files, err := ListLogFiles(config)
+    if err != nil {
+        t.Fatalf("failed to list log files: %v", err)
+    }
+    // The newest archive and plain backup; the oldest archive is removed below
+    var plain, archive string
+    for _, file := range files {
+        switch {
+        case file.Active:
+        case file.Compressed():
+            archive = file.Path
+        case !file.Compressed():
+            plain = file.Path
+        }
+    }
+
+    // Tampering with a backup, a manifest or the set of backups is reported
+    content, _ := os.ReadFile(plain)
+    os.WriteFile(plain, []byte(strings.Replace(string(content), "signed", "SIGNED", 1)), 0644)
+    manifest, _ := os.ReadFile(archive + SignatureExt)
+    os.WriteFile(archive+SignatureExt, []byte(strings.Replace(string(manifest), `"records": `, `"records": 1`, 1)), 0644)
+    os.Remove(files[0].Path)
+
+    expected := map[string]error{
+        plain:         ErrManifestMismatch,
+        archive:       ErrBadSignature,
+        files[0].Path: ErrMissingBackup,
+    }
+    results, err = VerifySignatures(config, keys)
+    if err != nil {
+        t.Fatalf("failed to verify signatures: %v", err)
+    }
+    for _, result := range results {
+        if want := expected[result.Path]; !errors.Is(result.Err, want) {
+            t.Errorf("%s: expected %v, got %v", result.Path, want, result.Err)
+        }
+    }
+}
+
+func TestRecoveryOnlySignsInterruptedBackups(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_signatures_recovery"
+    defer os.RemoveAll(tempDir)
+
+    keys := &Keyring{
+        ActiveKeyID: "2024-01",
+        Keys:        map[string][]byte{"2024-01": []byte("first secret key")},
+    }
+    events := map[string]RecoveryEvent{}
+    config := &Config{
+        LogDir:      tempDir,
+        FileName:    "resign",
+        MaxSize:     300,
+        MaxBackups:  100,
+        Level:       DEBUG,
+        SigningKeys: keys,
+        OnRecovery: func(event RecoveryEvent) {
+            events[event.Path] = event
+        },
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    for i := 0; i < 20; i++ {
+        logger.Info("signed record %d", i)
+    }
+    logger.Close()
+
+    // A backup tampered with and stripped of its manifest, and one whose
+    // signing a crash interrupted
+    files, _ := ListLogFiles(config)
+    tampered := files[0].Path
+    content, _ := os.ReadFile(tampered)
+    os.WriteFile(tampered, []byte(strings.Replace(string(content), "signed", "forged", 1)), 0644)
+    os.Remove(tampered + SignatureExt)
+    interrupted := filepath.Join(tempDir, logger.namer.format(time.Now(), logger.nextSeq))
+    os.WriteFile(interrupted, content, 0644)
+    logger.journalBackup(interrupted, interrupted)
+
+    logger, err = NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Close()
+
+    if event := events[tampered]; event.Action != RecoveryLeftUnsigned || !errors.Is(event.Err, ErrUnsigned) {
+        t.Errorf("expected the tampered backup to be left unsigned, got %+v", event)
+    }
+    if event := events[interrupted]; event.Action != RecoverySigned {
+        t.Errorf("expected the interrupted backup to be signed, got %+v", event)
+    }
+    if _, err := os.Stat(logger.journalPath()); !os.IsNotExist(err) {
+        t.Errorf("expected the journal to be removed once every backup is signed: %v", err)
+    }
+
+    // A journal that does not verify is ignored
+    os.WriteFile(logger.journalPath(), []byte(`{"files": ["`+filepath.Base(tampered)+`"], "key_id": "2024-01", "mac": "00"}`), 0644)
+    logger, err = NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Close()
+
+    results, err := VerifySignatures(config, keys)
+    if err != nil {
+        t.Fatalf("failed to verify signatures: %v", err)
+    }
+    for _, result := range results {
+        want := error(nil)
+        if result.Path == tampered {
+            want = ErrUnsigned
+        }
+        if result.Err != want {
+            t.Errorf("%s: expected %v, got %v", result.Path, want, result.Err)
+        }
+    }
+
+    // A journal restored after its backup was signed and then edited is
+    // bound to the content at rotation, so the edit is not signed
+    logger.journalBackup(interrupted, interrupted)
+    stale, err := os.ReadFile(logger.journalPath())
+    if err != nil {
+        t.Fatalf("failed to read journal: %v", err)
+    }
+    logger.unjournalBackup(interrupted)
+    os.WriteFile(interrupted, []byte(strings.Replace(string(content), "signed", "edited", 1)), 0644)
+    os.Remove(interrupted + SignatureExt)
+    os.WriteFile(logger.journalPath(), stale, 0644)
+
+    logger, err = NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    logger.Close()
+    if event := events[interrupted]; event.Action != RecoveryLeftUnsigned || !errors.Is(event.Err, errJournalMismatch) {
+        t.Errorf("expected the edited backup to be left unsigned, got %+v", event)
+    }
+    if _, err := os.Stat(interrupted + SignatureExt); !os.IsNotExist(err) {
+        t.Errorf("expected the edited backup to stay unsigned: %v", err)
+    }
+}
@@ -0,0 +1,264 @@
+package logr
+
+import (
+    "fmt"
+    "io"
+    "os"
+)
+
+// Reserved sink names used by the logger itself
+const (
+    FileSinkName   = "file"
+    StdoutSinkName = "stdout"
+    OutputSinkName = "output"
+)
+
+// Sink is a destination for log entries.
+// Sink methods are called with the logger's lock held, so a sink
+// must not call back into the logger that owns it.
+type Sink interface {
+    // Enabled reports whether the sink accepts entries at the given level
+    Enabled(level LogLevel) bool
+    // Write writes a single entry
+    Write(e *Entry) error
+    // Sync flushes buffered data to the underlying storage
+    Sync() error
+    // Close releases the sink's resources
+    Close() error
+}
+
+// namedSink is a sink registered on a logger under a name
+type namedSink struct {
+    name string
+    sink Sink
+}
+
+// WriterSink writes encoded entries to an io.Writer
+type WriterSink struct {
+    w       io.Writer
+    encoder Encoder
+    level   LogLevel
+}
+
+// NewWriterSink creates a sink that writes entries at or above level to w
+func NewWriterSink(w io.Writer, encoder Encoder, level LogLevel) *WriterSink {
+    if encoder == nil {
+        encoder = NewTextEncoder()
+    }
+    return &WriterSink{
+        w:       w,
+        encoder: encoder,
+        level:   level,
+    }
+}
+
+// Enabled implements Sink
+func (s *WriterSink) Enabled(level LogLevel) bool {
+    return level >= s.level
+}
+
+// Write implements Sink
+func (s *WriterSink) Write(e *Entry) error {
+    line, err := s.encoder.Encode(e)
+    if err != nil {
+        return fmt.Errorf("failed to encode log message: %v", err)
+    }
+    // Standard error goes through diagnostics, which bypasses a capture of it
+    w := s.w
+    if w == os.Stderr {
+        w = diagnostics
+    }
+    _, err = w.Write(line)
+    return err
+}
+
+// Sync implements Sink, syncing the writer if it supports it
+func (s *WriterSink) Sync() error {
+    if isStdStream(s.w) {
+        return nil
+    }
+    if syncer, ok := s.w.(interface{ Sync() error }); ok {
+        return syncer.Sync()
+    }
+    return nil
+}
+
+// Close implements Sink, closing the writer if it supports it.
+// The process's stdout and stderr are never closed.
+func (s *WriterSink) Close() error {
+    if err := s.Sync(); err != nil {
+        return err
+    }
+    if isStdStream(s.w) {
+        return nil
+    }
+    if closer, ok := s.w.(io.Closer); ok {
+        return closer.Close()
+    }
+    return nil
+}
+
+// isStdStream reports whether w is the process's stdout or stderr
+func isStdStream(w io.Writer) bool {
+    return w == os.Stdout || w == os.Stderr
+}
+
+// fileSink adapts the logger's rotating file to the Sink interface
+type fileSink struct {
+    l *Logger
+}
+
+// Enabled implements Sink; level filtering is done by the logger
+func (s *fileSink) Enabled(level LogLevel) bool {
+    return true
+}
+
+// Write implements Sink
+func (s *fileSink) Write(e *Entry) error {
+    return s.WriteBatch([]*Entry{e})
+}
+
+// WriteBatch implements batchSink
+func (s *fileSink) WriteBatch(entries []*Entry) error {
+    entries = s.l.filterDegraded(entries)
+    if len(entries) == 0 {
+        return nil
+    }
+    return s.l.writeFileBatch(entries)
+}
+
+// Sync implements Sink
+func (s *fileSink) Sync() error {
+    if fallback := s.l.config.FallbackSink; fallback != nil {
+        fallback.Sync()
+    }
+    if s.l.file != nil {
+        err := s.l.sealSegments()
+        if serr := s.l.file.Sync(); err == nil {
+            err = serr
+        }
+        return err
+    }
+    return nil
+}
+
+// Close implements Sink
+func (s *fileSink) Close() error {
+    if fallback := s.l.config.FallbackSink; fallback != nil {
+        fallback.Close()
+    }
+    if s.l.file != nil {
+        // Sync before closing to ensure all data is written
+        s.l.sealSegments()
+        s.l.file.Sync()
+        err := s.l.file.Close()
+        s.l.file = nil
+        return err
+    }
+    return nil
+}
+
+// AddSink registers an additional sink under name
+func (l *Logger) AddSink(name string, sink Sink) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    for _, s := range l.sinks {
+        if s.name == name {
+            return fmt.Errorf("sink %q already exists", name)
+        }
+    }
+    l.sinks = append(l.sinks, namedSink{name: name, sink: sink})
+    return nil
+}
+
+// RemoveSink unregisters and closes the sink registered under name
+func (l *Logger) RemoveSink(name string) error {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    sink := l.removeSinkLocked(name)
+    if sink == nil {
+        return fmt.Errorf("sink %q not found", name)
+    }
+    return sink.Close()
+}
+
+// removeSinkLocked unregisters the sink registered under name and returns it
+func (l *Logger) removeSinkLocked(name string) Sink {
+    for i, s := range l.sinks {
+        if s.name == name {
+            sinks := make([]namedSink, 0, len(l.sinks)-1)
+            sinks = append(sinks, l.sinks[:i]...)
+            l.sinks = append(sinks, l.sinks[i+1:]...)
+            return s.sink
+        }
+    }
+    return nil
+}
+
+// Sinks returns the names of the registered sinks in delivery order
+func (l *Logger) Sinks() []string {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    names := make([]string, 0, len(l.sinks))
+    for _, s := range l.sinks {
+        names = append(names, s.name)
+    }
+    return names
+}
+
+// SetOutput sets an additional output target, such as a network log collector.
+// It replaces the writer set by a previous call; a nil writer removes it.
+func (l *Logger) SetOutput(w io.Writer) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    if old := l.removeSinkLocked(OutputSinkName); old != nil {
+        old.Close()
+    }
+    if w != nil {
+        l.sinks = append(l.sinks, namedSink{name: OutputSinkName, sink: NewWriterSink(w, l.encoder, DEBUG)})
+    }
+}
+
+// writeSinks delivers an entry to every sink that accepts its level.
+// A failing sink does not stop delivery to the others.
+func (l *Logger) writeSinks(entry *Entry) {
+    for _, s := range l.sinks {
+        if !s.sink.Enabled(entry.Level) {
+            continue
+        }
+        if err := s.sink.Write(entry); err != nil {
+            reportSinkError(s.name, err)
+        }
+    }
+}
+
+// reportSinkError reports a failed sink write on the diagnostics stream
+func reportSinkError(name string, err error) {
+    fmt.Fprintf(diagnostics, "failed to write to log sink %s: %v\n", name, err)
+}
+
+// syncSinks syncs every sink and returns the first error
+func (l *Logger) syncSinks() error {
+    var firstErr error
+    for _, s := range l.sinks {
+        if err := s.sink.Sync(); err != nil && firstErr == nil {
+            firstErr = fmt.Errorf("failed to sync log sink %s: %v", s.name, err)
+        }
+    }
+    return firstErr
+}
+
+// closeSinks closes every sink and returns the first error
+func (l *Logger) closeSinks() error {
+    var firstErr error
+    for _, s := range l.sinks {
+        if err := s.sink.Close(); err != nil && firstErr == nil {
+            firstErr = fmt.Errorf("failed to close log sink %s: %v", s.name, err)
+        }
+    }
+    return firstErr
+}
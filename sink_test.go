@@ -0,0 +1,135 @@
+package logr
+
+import (
+    "bytes"
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// recordingSink records entries and can be made to fail
+type recordingSink struct {
+    level   LogLevel
+    fail    bool
+    entries []*Entry
+    synced  int
+    closed  bool
+}
+
+func (s *recordingSink) Enabled(level LogLevel) bool {
+    return level >= s.level
+}
+
+func (s *recordingSink) Write(e *Entry) error {
+    if s.fail {
+        return errors.New("sink unavailable")
+    }
+    s.entries = append(s.entries, e)
+    return nil
+}
+
+func (s *recordingSink) Sync() error {
+    s.synced++
+    return nil
+}
+
+func (s *recordingSink) Close() error {
+    s.closed = true
+    return nil
+}
+
+func TestSinkFanOut(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_sinks"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:       tempDir,
+        FileName:     "sinks_test",
+        MaxSize:      1024 * 1024,
+        MaxAge:       time.Hour,
+        MaxBackups:   3,
+        Level:        DEBUG,
+        EnableStdout: false,
+    }
+
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    var output bytes.Buffer
+    var jsonOutput bytes.Buffer
+    failing := &recordingSink{fail: true}
+    errorsOnly := &recordingSink{level: ERROR}
+
+    logger.SetOutput(&output)
+    if err := logger.AddSink("failing", failing); err != nil {
+        t.Fatalf("failed to add sink: %v", err)
+    }
+    if err := logger.AddSink("errors", errorsOnly); err != nil {
+        t.Fatalf("failed to add sink: %v", err)
+    }
+    if err := logger.AddSink("json", NewWriterSink(&jsonOutput, NewJSONEncoder(), WARN)); err != nil {
+        t.Fatalf("failed to add sink: %v", err)
+    }
+    if err := logger.AddSink("errors", errorsOnly); err == nil {
+        t.Error("expected an error when adding a duplicate sink name")
+    }
+
+    logger.Debug("debug record")
+    logger.Error("error record")
+
+    if !strings.Contains(output.String(), "debug record") || !strings.Contains(output.String(), "error record") {
+        t.Errorf("writer sink missed records: %q", output.String())
+    }
+    if strings.Contains(jsonOutput.String(), "debug record") || !strings.Contains(jsonOutput.String(), `"msg":"error record"`) {
+        t.Errorf("json sink did not honor its level or encoder: %q", jsonOutput.String())
+    }
+    if len(errorsOnly.entries) != 1 || errorsOnly.entries[0].Message != "error record" {
+        t.Errorf("expected only the error record in the leveled sink, got %d entries", len(errorsOnly.entries))
+    }
+
+    content, err := os.ReadFile(filepath.Join(tempDir, "sinks_test.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    if !strings.Contains(string(content), "error record") {
+        t.Error("a failing sink should not stop delivery to the file")
+    }
+
+    if err := logger.Sync(); err != nil {
+        t.Errorf("sync failed: %v", err)
+    }
+    if errorsOnly.synced != 1 {
+        t.Errorf("expected sync to propagate to sinks, got %d syncs", errorsOnly.synced)
+    }
+
+    if err := logger.RemoveSink("errors"); err != nil {
+        t.Errorf("failed to remove sink: %v", err)
+    }
+    if !errorsOnly.closed {
+        t.Error("expected removed sink to be closed")
+    }
+    logger.Error("after removal")
+    if len(errorsOnly.entries) != 1 {
+        t.Error("removed sink should not receive entries")
+    }
+
+    logger.SetOutput(nil)
+    logger.Info("after output reset")
+    if strings.Contains(output.String(), "after output reset") {
+        t.Error("SetOutput(nil) should remove the output sink")
+    }
+
+    if err := logger.Close(); err != nil {
+        t.Errorf("close failed: %v", err)
+    }
+    if !failing.closed {
+        t.Error("expected close to propagate to sinks")
+    }
+}
@@ -0,0 +1,141 @@
+//go:build go1.21
+// +build go1.21
+
+package logr
+
+import (
+    "context"
+    "log/slog"
+)
+
+// SlogHandler is a slog.Handler writing records through a Logger, so that
+// they share its sinks, rotation and redaction. Attributes become fields and
+// groups become group fields.
+type SlogHandler struct {
+    logger *Logger
+    scopes []slogScope // Groups and attributes added by WithGroup and WithAttrs, outermost first
+}
+
+// slogScope is either a group opened by WithGroup or the fields of WithAttrs
+type slogScope struct {
+    group  string
+    fields []Field
+}
+
+// NewSlogHandler creates a slog.Handler writing to logger, including the
+// fields of With
+func NewSlogHandler(logger *Logger) *SlogHandler {
+    return &SlogHandler{logger: logger}
+}
+
+// slogLevel maps a slog level onto the LogLevel of the range it falls in.
+// Levels above slog.LevelError map to ERROR, never to FATAL.
+func slogLevel(level slog.Level) LogLevel {
+    switch {
+    case level < slog.LevelInfo:
+        return DEBUG
+    case level < slog.LevelWarn:
+        return INFO
+    case level < slog.LevelError:
+        return WARN
+    default:
+        return ERROR
+    }
+}
+
+// Enabled implements slog.Handler
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+    return h.logger.Enabled(slogLevel(level))
+}
+
+// Handle implements slog.Handler
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+    fields := make([]Field, 0, r.NumAttrs())
+    r.Attrs(func(a slog.Attr) bool {
+        fields = appendSlogAttr(fields, a)
+        return true
+    })
+
+    // Wrap the record's fields in the open groups, innermost first, dropping
+    // groups left empty
+    for i := len(h.scopes) - 1; i >= 0; i-- {
+        scope := h.scopes[i]
+        switch {
+        case scope.group == "":
+            fields = append(append([]Field(nil), scope.fields...), fields...)
+        case len(fields) > 0:
+            fields = []Field{Group(scope.group, fields...)}
+        }
+    }
+
+    // A zero time is kept, so that encoders can omit it as slog requires
+    h.logger.writeEntry(&Entry{
+        Time:    r.Time,
+        Level:   slogLevel(r.Level),
+        Message: r.Message,
+        Fields:  h.logger.mergeFields(fields),
+    }, false)
+    return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+    var fields []Field
+    for _, a := range attrs {
+        fields = appendSlogAttr(fields, a)
+    }
+    if len(fields) == 0 {
+        return h
+    }
+    return h.with(slogScope{fields: fields})
+}
+
+// WithGroup implements slog.Handler
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+    if name == "" {
+        return h
+    }
+    return h.with(slogScope{group: name})
+}
+
+// with returns a copy of the handler with one more scope
+func (h *SlogHandler) with(scope slogScope) *SlogHandler {
+    scopes := make([]slogScope, len(h.scopes), len(h.scopes)+1)
+    copy(scopes, h.scopes)
+    return &SlogHandler{logger: h.logger, scopes: append(scopes, scope)}
+}
+
+// appendSlogAttr appends an attribute as a field after resolving its value.
+// Empty attributes and groups are skipped and the attributes of groups with
+// an empty key are appended inline.
+func appendSlogAttr(fields []Field, a slog.Attr) []Field {
+    a.Value = a.Value.Resolve()
+    if a.Equal(slog.Attr{}) {
+        return fields
+    }
+
+    switch a.Value.Kind() {
+    case slog.KindGroup:
+        var group []Field
+        for _, attr := range a.Value.Group() {
+            group = appendSlogAttr(group, attr)
+        }
+        if len(group) == 0 {
+            return fields
+        }
+        if a.Key == "" {
+            return append(fields, group...)
+        }
+        return append(fields, Group(a.Key, group...))
+    case slog.KindString:
+        return append(fields, String(a.Key, a.Value.String()))
+    case slog.KindInt64:
+        return append(fields, Int64(a.Key, a.Value.Int64()))
+    case slog.KindDuration:
+        return append(fields, Duration(a.Key, a.Value.Duration()))
+    case slog.KindTime:
+        return append(fields, Time(a.Key, a.Value.Time()))
+    default:
+        return append(fields, Any(a.Key, a.Value.Any()))
+    }
+}
@@ -0,0 +1,113 @@
+//go:build go1.21
+// +build go1.21
+
+package logr
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "log/slog"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "testing/slogtest"
+    "time"
+)
+
+func TestSlogHandler(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_slog"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:   tempDir,
+        FileName: "slog",
+        MaxSize:  1024 * 1024,
+        MaxAge:   time.Hour,
+        Level:    DEBUG,
+        Encoder:  NewJSONEncoder(),
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    err = slogtest.TestHandler(NewSlogHandler(logger), func() []map[string]interface{} {
+        logger.Sync()
+        data, err := os.ReadFile(filepath.Join(tempDir, "slog.log"))
+        if err != nil {
+            t.Fatalf("failed to read log file: %v", err)
+        }
+        var results []map[string]interface{}
+        for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+            var m map[string]interface{}
+            if err := json.Unmarshal(line, &m); err != nil {
+                t.Fatalf("invalid JSON %q: %v", line, err)
+            }
+            renameKey(m, "ts", slog.TimeKey)
+            if level, ok := m["level"].(string); ok {
+                m["level"] = strings.ToUpper(level)
+            }
+            results = append(results, m)
+        }
+        return results
+    })
+    if err != nil {
+        t.Error(err)
+    }
+}
+
+func TestSlogLevels(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_slog_levels"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:   tempDir,
+        FileName: "levels",
+        MaxSize:  1024 * 1024,
+        MaxAge:   time.Hour,
+        Level:    WARN,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    sl := slog.New(NewSlogHandler(logger.With(String("component", "db"))))
+    sl.Info("dropped")
+    sl.Log(context.Background(), slog.LevelWarn+2, "warning", "attempt", 3)
+    sl.WithGroup("req").Error("failed", "id", "r1", slog.Group("peer", "addr", "10.0.0.1"))
+    sl.Log(context.Background(), slog.LevelError+8, "not fatal")
+    logger.Close()
+
+    data, err := os.ReadFile(filepath.Join(tempDir, "levels.log"))
+    if err != nil {
+        t.Fatalf("failed to read log file: %v", err)
+    }
+    lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+    expected := []string{
+        "[WARN] warning component=db attempt=3",
+        "[ERROR] failed component=db req.id=r1 req.peer.addr=10.0.0.1",
+        "[ERROR] not fatal component=db",
+    }
+    if len(lines) != len(expected) {
+        t.Fatalf("expected %d lines, got %d: %q", len(expected), len(lines), lines)
+    }
+    for i, line := range lines {
+        if !strings.HasSuffix(line, expected[i]) {
+            t.Errorf("expected line %d to end with %q, got %q", i, expected[i], line)
+        }
+    }
+}
+
+// renameKey moves the value of a result key to the key slogtest expects
+func renameKey(m map[string]interface{}, from, to string) {
+    if v, ok := m[from]; ok {
+        m[to] = v
+        delete(m, from)
+    }
+}
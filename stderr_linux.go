@@ -0,0 +1,40 @@
+//go:build linux && go1.23
+// +build linux,go1.23
+
+package logr
+
+import (
+    "os"
+    "runtime/debug"
+    "syscall"
+)
+
+// redirectStderr points file descriptor 2 at w and the output of fatal
+// runtime errors at crash. It returns a duplicate of the original standard
+// error.
+func redirectStderr(w, crash *os.File) (*os.File, error) {
+    fd, err := syscall.Dup(2)
+    if err != nil {
+        return nil, err
+    }
+    syscall.CloseOnExec(fd)
+    saved := os.NewFile(uintptr(fd), "/dev/stderr")
+
+    if err := debug.SetCrashOutput(crash, debug.CrashOptions{}); err != nil {
+        saved.Close()
+        return nil, err
+    }
+    if err := syscall.Dup3(int(w.Fd()), 2, 0); err != nil {
+        debug.SetCrashOutput(nil, debug.CrashOptions{})
+        saved.Close()
+        return nil, err
+    }
+    return saved, nil
+}
+
+// restoreStderr points file descriptor 2 back at saved and stops writing
+// fatal runtime errors to the crash file
+func restoreStderr(saved *os.File) error {
+    debug.SetCrashOutput(nil, debug.CrashOptions{})
+    return syscall.Dup3(int(saved.Fd()), 2, 0)
+}
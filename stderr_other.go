@@ -0,0 +1,16 @@
+//go:build !linux || !go1.23
+// +build !linux !go1.23
+
+package logr
+
+import "os"
+
+// redirectStderr is not supported on this platform
+func redirectStderr(w, crash *os.File) (*os.File, error) {
+    return nil, errStderrUnsupported
+}
+
+// restoreStderr is not supported on this platform
+func restoreStderr(saved *os.File) error {
+    return errStderrUnsupported
+}
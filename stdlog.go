@@ -0,0 +1,258 @@
+package logr
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "sync"
+    "time"
+)
+
+// maxLineLength bounds the partial line a line writer buffers; longer lines
+// are split into several records
+const maxLineLength = 64 * 1024
+
+// stderrReleaseTimeout bounds the wait for the last captured output when
+// standard error is released, as child processes may keep the pipe open
+const stderrReleaseTimeout = time.Second
+
+var (
+    errStderrCaptured    = errors.New("standard error is already captured")
+    errStderrUnsupported = errors.New("capturing standard error is not supported on this platform")
+)
+
+// diagnosticWriter writes the logger's own warnings and errors to standard
+// error, or to the original standard error while it is captured, so that they
+// cannot feed back into the logger
+type diagnosticWriter struct {
+    mu sync.RWMutex
+    w  io.Writer // Overrides os.Stderr if set
+}
+
+// diagnostics receives the logger's own warnings and errors
+var diagnostics = &diagnosticWriter{}
+
+// Write implements io.Writer
+func (d *diagnosticWriter) Write(p []byte) (int, error) {
+    d.mu.RLock()
+    w := d.w
+    d.mu.RUnlock()
+    if w == nil {
+        w = os.Stderr
+    }
+    return w.Write(p)
+}
+
+// set redirects diagnostics to w, or back to os.Stderr if w is nil
+func (d *diagnosticWriter) set(w io.Writer) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.w = w
+}
+
+// Writer returns a writer that logs each line written to it as a record at
+// level. Partial lines are buffered until their newline arrives.
+func (l *Logger) Writer(level LogLevel) io.Writer {
+    return &lineWriter{logger: l, level: level}
+}
+
+// lineWriter logs each line written to it as a record
+type lineWriter struct {
+    logger *Logger
+    level  LogLevel
+    mu     sync.Mutex
+    buf    []byte // Incomplete last line
+}
+
+// Write implements io.Writer
+func (w *lineWriter) Write(p []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    w.buf = append(w.buf, p...)
+    for {
+        i := bytes.IndexByte(w.buf, '\n')
+        if i < 0 {
+            break
+        }
+        w.writeLine(w.buf[:i])
+        w.buf = w.buf[i+1:]
+    }
+    for len(w.buf) >= maxLineLength {
+        w.writeLine(w.buf[:maxLineLength])
+        w.buf = w.buf[maxLineLength:]
+    }
+    // Release the consumed lines once nothing is pending
+    if len(w.buf) == 0 {
+        w.buf = nil
+    }
+    return len(p), nil
+}
+
+// flush logs the buffered partial line, if any
+func (w *lineWriter) flush() {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if len(w.buf) > 0 {
+        w.writeLine(w.buf)
+        w.buf = nil
+    }
+}
+
+// writeLine logs one line, dropping a trailing carriage return
+func (w *lineWriter) writeLine(line []byte) {
+    line = bytes.TrimSuffix(line, []byte("\r"))
+    if len(line) > 0 {
+        w.logger.writeLog(w.level, string(line), nil)
+    }
+}
+
+// stdLogWriter logs each write of a standard library logger as one record,
+// so that multiline messages stay together
+type stdLogWriter struct {
+    logger *Logger
+    level  LogLevel
+}
+
+// Write implements io.Writer
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+    w.logger.writeLog(w.level, string(bytes.TrimSuffix(p, []byte("\n"))), nil)
+    return len(p), nil
+}
+
+// StdLogger returns a standard library logger writing each message as a
+// record at level. Its flags are 0, as records carry their own timestamp.
+func (l *Logger) StdLogger(level LogLevel) *log.Logger {
+    return log.New(&stdLogWriter{logger: l, level: level}, "", 0)
+}
+
+// RedirectStdLog sends the output of the standard library's default logger,
+// as used by log.Printf, to l as records at level. The returned function
+// restores the previous output, prefix and flags.
+func RedirectStdLog(l *Logger, level LogLevel) func() {
+    output, prefix, flags := log.Writer(), log.Prefix(), log.Flags()
+    log.SetOutput(&stdLogWriter{logger: l, level: level})
+    log.SetFlags(0)
+    return func() {
+        log.SetOutput(output)
+        log.SetPrefix(prefix)
+        log.SetFlags(flags)
+    }
+}
+
+// stderrCapture is the active capture of the process's standard error
+type stderrCapture struct {
+    owner  *loggerState
+    saved  *os.File // Duplicate of the original standard error
+    reader *os.File // Read end of the pipe standard error is redirected to
+    writer *os.File // Write end of the pipe
+    crash  *os.File // Receives the output of fatal runtime errors
+    done   chan struct{}
+}
+
+var (
+    captureMu sync.Mutex
+    capture   *stderrCapture
+)
+
+// CaptureStderr redirects the process's standard error, file descriptor 2,
+// into the logger, so that output of libraries and child processes writing
+// there becomes records at level. Fatal panics and runtime errors are also
+// appended to <FileName>.crash in the log directory, as the process exits
+// before their output could be logged. The logger's own diagnostics and
+// sinks writing to os.Stderr keep writing to the original standard error.
+//
+// Capturing is supported on Linux with Go 1.23 or later. Only one logger can
+// capture standard error at a time; it is released by the returned function
+// or when the logger is closed.
+func (l *Logger) CaptureStderr(level LogLevel) (func() error, error) {
+    captureMu.Lock()
+    defer captureMu.Unlock()
+    if capture != nil {
+        return nil, errStderrCaptured
+    }
+
+    crash, err := os.OpenFile(l.crashPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+    if err != nil {
+        return nil, err
+    }
+    reader, writer, err := os.Pipe()
+    if err != nil {
+        crash.Close()
+        return nil, err
+    }
+    saved, err := redirectStderr(writer, crash)
+    if err != nil {
+        crash.Close()
+        reader.Close()
+        writer.Close()
+        return nil, err
+    }
+    diagnostics.set(saved)
+
+    c := &stderrCapture{
+        owner:  l.loggerState,
+        saved:  saved,
+        reader: reader,
+        writer: writer,
+        crash:  crash,
+        done:   make(chan struct{}),
+    }
+    capture = c
+    go func() {
+        defer close(c.done)
+        lines := &lineWriter{logger: l, level: level}
+        io.Copy(lines, reader)
+        lines.flush()
+    }()
+
+    return func() error {
+        captureMu.Lock()
+        defer captureMu.Unlock()
+        if capture != c {
+            return nil
+        }
+        return releaseStderr()
+    }, nil
+}
+
+// crashPath returns the path of the file receiving fatal runtime errors
+func (l *Logger) crashPath() string {
+    return filepath.Join(l.config.LogDir, l.config.FileName+".crash")
+}
+
+// releaseStderrOwnedBy releases the capture of standard error if the logger
+// state owns it
+func releaseStderrOwnedBy(owner *loggerState) error {
+    captureMu.Lock()
+    defer captureMu.Unlock()
+    if capture == nil || capture.owner != owner {
+        return nil
+    }
+    return releaseStderr()
+}
+
+// releaseStderr points standard error back at its original file and logs
+// the output still in the pipe. captureMu must be held.
+func releaseStderr() error {
+    c := capture
+    capture = nil
+
+    err := restoreStderr(c.saved)
+    diagnostics.set(nil)
+    c.writer.Close()
+
+    select {
+    case <-c.done:
+    case <-time.After(stderrReleaseTimeout):
+        c.reader.Close()
+        <-c.done
+    }
+    c.reader.Close()
+    c.saved.Close()
+    c.crash.Close()
+    return err
+}
@@ -0,0 +1,142 @@
+package logr
+
+import (
+    "fmt"
+    "log"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestStdLogAdapter(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_stdlog"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:   tempDir,
+        FileName: "stdlog",
+        MaxSize:  1024 * 1024,
+        MaxAge:   time.Hour,
+        Level:    INFO,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+
+    logger.StdLogger(WARN).Printf("deprecated option %q", "x")
+    logger.StdLogger(ERROR).Print("first\nsecond")
+    logger.StdLogger(DEBUG).Print("below the level")
+
+    w := logger.Writer(INFO)
+    fmt.Fprint(w, "part")
+    fmt.Fprint(w, "ial\r\nnext\n\n")
+
+    output := log.Writer()
+    restore := RedirectStdLog(logger, WARN)
+    log.Printf("from the standard logger")
+    restore()
+    if log.Writer() != output {
+        t.Error("expected the standard logger's output to be restored")
+    }
+    logger.Close()
+
+    messages := readMessages(t, config)
+    expected := []string{`deprecated option "x"`, "first\nsecond", "partial", "next", "from the standard logger"}
+    if fmt.Sprint(messages) != fmt.Sprint(expected) {
+        t.Errorf("expected %q, got %q", expected, messages)
+    }
+}
+
+func TestCaptureStderr(t *testing.T) {
+    // Create temporary directory
+    tempDir := "./test_logs_stderr"
+    defer os.RemoveAll(tempDir)
+
+    config := &Config{
+        LogDir:   tempDir,
+        FileName: "stderr",
+        MaxSize:  1024 * 1024,
+        MaxAge:   time.Hour,
+        Level:    DEBUG,
+    }
+    logger, err := NewLogger(config)
+    if err != nil {
+        t.Fatalf("failed to create logger: %v", err)
+    }
+    defer logger.Close()
+
+    release, err := logger.CaptureStderr(WARN)
+    if err == errStderrUnsupported {
+        t.Skip(err)
+    }
+    if err != nil {
+        t.Fatalf("failed to capture stderr: %v", err)
+    }
+    if _, err := logger.CaptureStderr(WARN); err != errStderrCaptured {
+        t.Errorf("expected errStderrCaptured, got %v", err)
+    }
+
+    fmt.Fprintln(os.Stderr, "library warning")
+    cmd := exec.Command("sh", "-c", "echo from child >&2; printf unterminated >&2")
+    cmd.Stderr = os.Stderr
+    if err := cmd.Run(); err != nil {
+        t.Fatalf("failed to run child: %v", err)
+    }
+    if diagnostics.w == nil {
+        t.Error("expected diagnostics to bypass the capture")
+    }
+    if err := release(); err != nil {
+        t.Fatalf("failed to release stderr: %v", err)
+    }
+    if diagnostics.w != nil {
+        t.Error("expected diagnostics to write to stderr again")
+    }
+    logger.Sync()
+
+    messages := readMessages(t, config)
+    expected := []string{"library warning", "from child", "unterminated"}
+    if fmt.Sprint(messages) != fmt.Sprint(expected) {
+        t.Errorf("expected %q, got %q", expected, messages)
+    }
+}
+
+func TestCaptureStderrCrash(t *testing.T) {
+    // The child process captures stderr and crashes
+    if dir := os.Getenv("LOGR_TEST_CRASH_DIR"); dir != "" {
+        logger, err := NewLogger(&Config{LogDir: dir, FileName: "crash", MaxSize: 1024 * 1024, Level: DEBUG})
+        if err != nil {
+            t.Fatalf("failed to create logger: %v", err)
+        }
+        if _, err := logger.CaptureStderr(ERROR); err != nil {
+            t.Fatalf("failed to capture stderr: %v", err)
+        }
+        panic("boom")
+    }
+
+    // Create temporary directory
+    tempDir := "./test_logs_stderr_crash"
+    defer os.RemoveAll(tempDir)
+
+    cmd := exec.Command(os.Args[0], "-test.run=^TestCaptureStderrCrash$")
+    cmd.Env = append(os.Environ(), "LOGR_TEST_CRASH_DIR="+tempDir)
+    output, err := cmd.CombinedOutput()
+    if err == nil {
+        t.Fatal("expected the child process to crash")
+    }
+    if strings.Contains(string(output), "capture stderr") {
+        t.Skip(strings.TrimSpace(string(output)))
+    }
+
+    data, err := os.ReadFile(filepath.Join(tempDir, "crash.crash"))
+    if err != nil {
+        t.Fatalf("failed to read crash file: %v", err)
+    }
+    if !strings.Contains(string(data), "panic: boom") || !strings.Contains(string(data), "goroutine") {
+        t.Errorf("expected the panic in the crash file, got %q", data)
+    }
+}